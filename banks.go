@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"waveboard/fixes/ui"
+
+	"github.com/moutend/go-hook/pkg/types"
+)
+
+// Every binding belongs to a bank, so a key can play a different track in each bank.
+// g_keysMap only holds the bindings of the active bank and is rebuilt when switching.
+
+type BankKey struct {
+	Bank int
+	Key  types.VKCode
+}
+
+const maxBankCount = 32
+
+var g_bankLabel *ui.Label
+
+// ActiveBinding returns the binding of the track if it is in the active bank, as shown in the files table.
+
+func (track *AudioTrack) ActiveBinding() types.VKCode {
+	if track.Bank != g_appSettings.ActiveBank {
+		return 0
+	}
+
+	return track.Binding
+}
+
+// UnmapBinding leaves the track binding as is and only removes it from g_keysMap, if it was mapped there.
+
+func (track *AudioTrack) UnmapBinding() {
+	if track.Binding != 0 && g_keysMap[track.Binding] == track {
+		delete(g_keysMap, track.Binding)
+	}
+}
+
+func findBankTrack(bank int, key types.VKCode) *AudioTrack {
+	for _, item := range g_tracksList {
+		if item.Binding == key && item.Bank == bank {
+			return item
+		}
+	}
+
+	return nil
+}
+
+// clearKeyBindings unbinds the key from the tracks of every bank.
+
+func clearKeyBindings(key types.VKCode) {
+	for _, item := range g_tracksList {
+		if item.Binding != key {
+			continue
+		}
+
+		track := item
+
+		track.UnmapBinding()
+		track.Binding = 0
+		track.Bank = 0
+		track.SaveSettings()
+
+		g_filesTableModel.RowChanged(track.GetRow())
+
+		ui.QueueMain(func() { logToEntry("Unbound %s from #%s %s", formatKey(key), track.Label(), track.Name) })
+	}
+}
+
+func mapActiveBank() {
+	keysMap := make(map[types.VKCode]*AudioTrack)
+
+	for _, item := range g_tracksList {
+		if item.Binding != 0 && item.Bank == g_appSettings.ActiveBank {
+			keysMap[item.Binding] = item
+		}
+	}
+
+	g_keysMap = keysMap
+}
+
+func updateBankDisplay() {
+	bankText := fmt.Sprintf("Bank %d of %d", g_appSettings.ActiveBank+1, g_appSettings.BankCount)
+
+	if g_bankLabel != nil {
+		g_bankLabel.SetText(bankText)
+	}
+
+	if g_appSettings.BankCount > 1 {
+		g_mainWindow.SetTitle(appName + " - " + bankText)
+	} else {
+		g_mainWindow.SetTitle(appName)
+	}
+}
+
+// selectBank runs on the interface thread, as the files table shows the bindings of the active bank.
+
+func selectBank(bank int) {
+	if bank < 0 || bank >= g_appSettings.BankCount || bank == g_appSettings.ActiveBank {
+		return
+	}
+
+	g_bindingRow = -1
+	g_appSettings.ActiveBank = bank
+
+	mapActiveBank()
+	updateBankDisplay()
+
+	g_filesTableModel.RowInserted(0)
+
+	go trySaveSettings()
+}
+
+func cycleBank(step int) {
+	selectBank((g_appSettings.ActiveBank + step + g_appSettings.BankCount) % g_appSettings.BankCount)
+}
+
+// setBankCount keeps the bindings of removed banks, which come back when the count is raised again.
+
+func setBankCount(count int) {
+	g_appSettings.BankCount = count
+
+	if g_appSettings.ActiveBank >= count {
+		g_appSettings.ActiveBank = 0
+
+		mapActiveBank()
+
+		g_filesTableModel.RowInserted(0)
+	}
+
+	hiddenBindings := 0
+
+	for _, item := range g_tracksList {
+		if item.Binding != 0 && item.Bank >= count {
+			hiddenBindings++
+		}
+	}
+
+	if hiddenBindings != 0 {
+		logToEntry("%d bindings are in banks above %d and stay saved until the bank count is raised", hiddenBindings, count)
+	}
+
+	updateBankDisplay()
+
+	go trySaveSettings()
+}
+
+func makeBankGrid() ui.Control {
+	bankGrid := ui.NewGrid()
+	bankGrid.SetPadded(true)
+
+	previousButton := ui.NewButton("Previous bank")
+	previousButton.OnClicked(func(b *ui.Button) {
+		cycleBank(-1)
+	})
+
+	bankGrid.Append(previousButton, 0, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	g_bankLabel = ui.NewLabel("")
+
+	bankGrid.Append(g_bankLabel, 1, 0, 1, 1, false, ui.AlignCenter, false, ui.AlignCenter)
+
+	nextButton := ui.NewButton("Next bank")
+	nextButton.OnClicked(func(b *ui.Button) {
+		cycleBank(1)
+	})
+
+	bankGrid.Append(nextButton, 2, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	updateBankDisplay()
+
+	return bankGrid
+}
+
+func makeBankCountGrid() ui.Control {
+	countGrid := ui.NewGrid()
+	countGrid.SetPadded(true)
+
+	countEntry := ui.NewEntry()
+	countEntry.SetText(strconv.Itoa(g_appSettings.BankCount))
+
+	countGrid.Append(countEntry, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	countButton := ui.NewButton("Apply new bank count")
+	countButton.OnClicked(func(b *ui.Button) {
+		newCount, convError := strconv.Atoi(countEntry.Text())
+
+		if convError != nil {
+			logToEntry(convError.Error())
+
+			return
+		}
+
+		if newCount < 1 || newCount > maxBankCount {
+			countEntry.SetText(strconv.Itoa(g_appSettings.BankCount))
+
+			logToEntry("Bank count must be between 1 and %d", maxBankCount)
+
+			return
+		}
+
+		setBankCount(newCount)
+	})
+
+	countGrid.Append(countButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	return countGrid
+}
@@ -0,0 +1,740 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"waveboard/fixes/ui"
+)
+
+// https://developer.valvesoftware.com/wiki/Source_RCON_Protocol
+
+type RCONClient struct {
+	Address    string
+	Password   string
+	Connection net.Conn
+	RequestID  int32
+	Mutex      sync.Mutex
+}
+
+type ReplyTableModel struct{}
+
+const (
+	feedbackNone = iota
+	feedbackRCON
+	feedbackFile
+)
+
+const defaultRCONAddress = "127.0.0.1:27015"
+const rconTimeout = 3 * time.Second
+const rconMaxBody = 4096
+const rconResponseValue = 0
+const rconExecCommand = 2
+const rconAuthResponse = 2
+const rconAuth = 3
+const maxReplyLength = 127
+const replyQueueSize = 32
+
+const replyHelpMessage = `{user} - name of the player who used the command
+{command} - name of the command
+{arg} - argument of the command
+{id} - ID of the track
+{name} - name of the track
+{position} - position within the queue
+{limit} - queue entries limit
+{count} - number of queue entries
+{matches} - closest tracks when a name is ambiguous
+{volume} - new volume
+{samplerate} - new sample rate
+{error} - error message`
+
+var feedbackModesName []string = []string{
+	"Disabled",
+	"RCON",
+	"Command file",
+}
+
+var replyKeysList []string = []string{
+	"denied",
+	"play.playing",
+	"play.queued",
+	"play.notfound",
+	"play.ambiguous",
+	"play.queuefull",
+	"play.quota",
+	"fplay.playing",
+	"fplay.notfound",
+	"fplay.ambiguous",
+	"fplay.failed",
+	"volume.changed",
+	"volume.invalid",
+	"gvolume.changed",
+	"gvolume.invalid",
+	"samplerate.changed",
+	"samplerate.invalid",
+	"video.playing",
+	"video.queued",
+	"video.queuefull",
+	"video.quota",
+	"video.invalid",
+	"fvideo.invalid",
+	"skip.skipped",
+	"playlist.queued",
+	"playlist.notfound",
+	"playlist.empty",
+	"playlist.queuefull",
+	"playlist.quota",
+	"random.playing",
+	"random.queued",
+	"random.notfound",
+	"random.invalid",
+	"random.queuefull",
+	"random.quota",
+	"macro.running",
+	"macro.notfound",
+	"macro.queuefull",
+	"macro.quota",
+	"skipall.cleared",
+	"pause.paused",
+	"resume.resumed",
+	"seek.seeked",
+	"seek.invalid",
+	"remove.removed",
+	"remove.invalid",
+	"move.moved",
+	"move.invalid",
+	"shuffle.shuffled",
+	"dedupe.removed",
+	"playnow.playing",
+	"playnow.invalid",
+	"playnow.failed",
+	"block.added",
+	"allow.added",
+	"removeblock.removed",
+	"removeallow.removed",
+}
+
+var defaultReplyTemplates map[string]string = map[string]string{
+	"denied":              "{user}: you are not allowed to use {command}",
+	"play.playing":        "Playing #{id}: {name}",
+	"play.queued":         "Queued #{id}: {name} (position {position})",
+	"play.notfound":       "{user}: no track named {arg}",
+	"play.ambiguous":      "{user}: did you mean {matches}?",
+	"play.queuefull":      "{user}: the queue is full ({limit} entries)",
+	"play.quota":          "{user}: you already have {limit} queued tracks",
+	"fplay.playing":       "Playing #{id}: {name}",
+	"fplay.notfound":      "{user}: no track named {arg}",
+	"fplay.ambiguous":     "{user}: did you mean {matches}?",
+	"fplay.failed":        "Could not play {name}: {error}",
+	"volume.changed":      "Volume of {name} set to {volume}%",
+	"volume.invalid":      "{user}: {arg} is not a valid volume",
+	"gvolume.changed":     "Global volume set to {volume}%",
+	"gvolume.invalid":     "{user}: {arg} is not a valid volume",
+	"samplerate.changed":  "Sample rate set to {samplerate} Hz",
+	"samplerate.invalid":  "{user}: {arg} is not a valid sample rate",
+	"video.playing":       "Playing video {name}",
+	"video.queued":        "Queued video {name} (position {position})",
+	"video.queuefull":     "{user}: the queue is full ({limit} entries)",
+	"video.quota":         "{user}: you already have {limit} queued tracks",
+	"video.invalid":       "{user}: {error}",
+	"fvideo.invalid":      "{user}: {error}",
+	"skip.skipped":        "Skipped {name}",
+	"playlist.queued":     "Queued {count} tracks of {name}",
+	"playlist.notfound":   "{user}: no playlist named {arg}",
+	"playlist.empty":      "{user}: playlist {name} has no tracks",
+	"playlist.queuefull":  "{user}: the queue is full ({limit} entries)",
+	"playlist.quota":      "{user}: you already have {limit} queued tracks",
+	"random.playing":      "Playing #{id}: {name}",
+	"random.queued":       "Queued #{id}: {name} (position {position})",
+	"random.notfound":     "{user}: no tracks match {arg}",
+	"random.invalid":      "{user}: {error}",
+	"random.queuefull":    "{user}: the queue is full ({limit} entries)",
+	"random.quota":        "{user}: you already have {limit} queued tracks",
+	"macro.running":       "Running macro {name}",
+	"macro.notfound":      "{user}: no macro named {arg}",
+	"macro.queuefull":     "{user}: macro {name} stopped, the queue is full ({limit} entries)",
+	"macro.quota":         "{user}: macro {name} stopped, you already have {limit} queued tracks",
+	"skipall.cleared":     "Cleared {count} queued tracks",
+	"pause.paused":        "Paused {name}",
+	"resume.resumed":      "Resumed {name}",
+	"seek.seeked":         "Seeked {name} to {position}",
+	"seek.invalid":        "{user}: {error}",
+	"remove.removed":      "Removed #{id}: {name} from position {position}",
+	"remove.invalid":      "{user}: {error}",
+	"move.moved":          "Moved #{id}: {name} to position {position}",
+	"move.invalid":        "{user}: {error}",
+	"shuffle.shuffled":    "Shuffled {count} queued tracks",
+	"dedupe.removed":      "Removed {count} duplicated tracks from the queue",
+	"playnow.playing":     "Playing #{id}: {name}",
+	"playnow.invalid":     "{user}: {error}",
+	"playnow.failed":      "Could not play {name}: {error}",
+	"block.added":         "Blocked {arg}",
+	"allow.added":         "Allowed {arg}",
+	"removeblock.removed": "Unblocked {arg}",
+	"removeallow.removed": "Removed {arg} from the allowed list",
+}
+
+// Quotes, semicolons and line breaks would let chat users run arbitrary console commands.
+
+var replySanitizer = strings.NewReplacer(
+	`"`, "'",
+	";", ",",
+	"\r", " ",
+	"\n", " ",
+)
+
+// g_rconMutex guards the client, used by the feedback goroutine and closed when the application exits.
+
+var g_rconClient *RCONClient
+var g_rconMutex sync.Mutex
+var g_replyQueue chan string
+var g_replyModel *ui.TableModel
+
+func setupFeedback() {
+	g_replyQueue = make(chan string, replyQueueSize)
+
+	go feedbackCallback()
+}
+
+func cleanFeedback() {
+	g_rconMutex.Lock()
+	defer g_rconMutex.Unlock()
+
+	if g_rconClient != nil {
+		g_rconClient.Close()
+		g_rconClient = nil
+	}
+}
+
+func feedbackCallback() {
+	for replyText := range g_replyQueue {
+		if feedbackError := writeFeedback(replyText); feedbackError != nil {
+			ui.QueueMain(func() { logToEntry(feedbackError.Error()) })
+		}
+	}
+}
+
+func getReplyTemplate(key string) string {
+	if template, exists := g_appSettings.ReplyTemplates[key]; exists {
+		return template
+	}
+
+	return defaultReplyTemplates[key]
+}
+
+func sendReply(key string, replacements ...string) {
+	if g_appSettings.FeedbackMode == feedbackNone || g_replyQueue == nil {
+		return
+	}
+
+	if replyText := formatReply(key, replacements...); replyText != "" {
+		queueReply(replyText)
+	}
+}
+
+// formatReply fills the template of the key, which is empty when the reply is disabled.
+// The replacements come from chat users, so they are sanitized along with the template.
+
+func formatReply(key string, replacements ...string) string {
+	template := getReplyTemplate(key)
+
+	if template == "" {
+		return ""
+	}
+
+	replyText := strings.TrimSpace(replySanitizer.Replace(strings.NewReplacer(replacements...).Replace(template)))
+
+	return truncateReply(replyText)
+}
+
+// truncateReply cuts the reply to maxReplyLength bytes, without splitting a character.
+
+func truncateReply(replyText string) string {
+	if len(replyText) <= maxReplyLength {
+		return replyText
+	}
+
+	cutIndex := maxReplyLength
+
+	for cutIndex > 0 && !utf8.RuneStart(replyText[cutIndex]) {
+		cutIndex--
+	}
+
+	return replyText[:cutIndex]
+}
+
+func queueReply(replyText string) {
+	select {
+	case g_replyQueue <- replyText:
+	default:
+		ui.QueueMain(func() { logToEntry("Dropped chat reply \"%s\"", replyText) })
+	}
+}
+
+func replyQueued(commandName string, playerName string, track *AudioTrack, position int) {
+	if position == 0 {
+		sendReply(commandName+".playing", "{user}", playerName, "{id}", track.Label(), "{name}", track.Name)
+
+		return
+	}
+
+	sendReply(commandName+".queued", "{user}", playerName, "{id}", track.Label(), "{name}", track.Name,
+		"{position}", strconv.Itoa(position))
+}
+
+func writeFeedback(replyText string) error {
+	switch g_appSettings.FeedbackMode {
+	case feedbackRCON:
+		return sendRCONReply(replyText)
+	case feedbackFile:
+		return writeReplyFile(replyText)
+	}
+
+	return nil
+}
+
+func sendRCONReply(replyText string) error {
+	g_rconMutex.Lock()
+	defer g_rconMutex.Unlock()
+
+	if g_rconClient == nil ||
+		g_rconClient.Address != g_appSettings.RCONAddress ||
+		g_rconClient.Password != g_appSettings.RCONPassword {
+		if g_rconClient != nil {
+			g_rconClient.Close()
+		}
+
+		g_rconClient = &RCONClient{
+			Address:  g_appSettings.RCONAddress,
+			Password: g_appSettings.RCONPassword,
+		}
+	}
+
+	_, executeError := g_rconClient.Execute("say \"" + replyText + "\"")
+
+	if executeError == nil {
+		return nil
+	}
+
+	// The game drops idle connections, so try once more with a new one
+
+	g_rconClient.Close()
+
+	_, executeError = g_rconClient.Execute("say \"" + replyText + "\"")
+
+	return executeError
+}
+
+func writeReplyFile(replyText string) error {
+	if g_appSettings.FeedbackFile == "" {
+		return errors.New("WriteReplyFile : No command file set")
+	}
+
+	return os.WriteFile(filepath.FromSlash(g_appSettings.FeedbackFile), []byte("say \""+replyText+"\"\r\n"), 0644)
+}
+
+func (client *RCONClient) Connect() error {
+	connection, dialError := net.DialTimeout("tcp", client.Address, rconTimeout)
+
+	if dialError != nil {
+		return dialError
+	}
+
+	client.Connection = connection
+
+	authID, writeError := client.WritePacket(rconAuth, client.Password)
+
+	if writeError != nil {
+		client.disconnect()
+
+		return writeError
+	}
+
+	for {
+		responseID, responseType, _, readError := client.ReadPacket()
+
+		if readError != nil {
+			client.disconnect()
+
+			return readError
+		}
+
+		if responseType != rconAuthResponse {
+			continue
+		}
+
+		if responseID == -1 || responseID != authID {
+			client.disconnect()
+
+			return errors.New("RCON : Authentication failed")
+		}
+
+		return nil
+	}
+}
+
+func (client *RCONClient) Execute(command string) (string, error) {
+	client.Mutex.Lock()
+	defer client.Mutex.Unlock()
+
+	if client.Connection == nil {
+		if connectError := client.Connect(); connectError != nil {
+			return "", connectError
+		}
+	}
+
+	commandID, writeError := client.WritePacket(rconExecCommand, command)
+
+	if writeError != nil {
+		return "", writeError
+	}
+
+	// Responses may be split into multiple packets.
+	// The server mirrors an empty response value packet, which marks the end of the previous response.
+
+	endID, writeError := client.WritePacket(rconResponseValue, "")
+
+	if writeError != nil {
+		return "", writeError
+	}
+
+	var response strings.Builder
+
+	for {
+		responseID, _, responseBody, readError := client.ReadPacket()
+
+		if readError != nil {
+			return response.String(), readError
+		}
+
+		if responseID == endID {
+			return response.String(), nil
+		}
+
+		if responseID != commandID {
+			continue
+		}
+
+		response.WriteString(responseBody)
+	}
+}
+
+func (client *RCONClient) WritePacket(packetType int32, body string) (int32, error) {
+	if len(body) > rconMaxBody {
+		return 0, errors.New("RCON : Packet body is too long")
+	}
+
+	client.RequestID++
+
+	packet := make([]byte, 14+len(body))
+	binary.LittleEndian.PutUint32(packet[0:], uint32(10+len(body)))
+	binary.LittleEndian.PutUint32(packet[4:], uint32(client.RequestID))
+	binary.LittleEndian.PutUint32(packet[8:], uint32(packetType))
+	copy(packet[12:], body)
+
+	client.Connection.SetWriteDeadline(time.Now().Add(rconTimeout))
+
+	_, writeError := client.Connection.Write(packet)
+
+	return client.RequestID, writeError
+}
+
+func (client *RCONClient) ReadPacket() (int32, int32, string, error) {
+	client.Connection.SetReadDeadline(time.Now().Add(rconTimeout))
+
+	var header [12]byte
+
+	if _, readError := io.ReadFull(client.Connection, header[:]); readError != nil {
+		return 0, 0, "", readError
+	}
+
+	packetSize := int32(binary.LittleEndian.Uint32(header[0:]))
+
+	if packetSize < 10 || packetSize > rconMaxBody+10 {
+		return 0, 0, "", errors.New("RCON : Invalid packet size")
+	}
+
+	body := make([]byte, packetSize-8)
+
+	if _, readError := io.ReadFull(client.Connection, body); readError != nil {
+		return 0, 0, "", readError
+	}
+
+	return int32(binary.LittleEndian.Uint32(header[4:])),
+		int32(binary.LittleEndian.Uint32(header[8:])),
+		strings.TrimRight(string(body), "\x00"),
+		nil
+}
+
+// Close waits for a running Execute, which holds the mutex while it uses the connection.
+
+func (client *RCONClient) Close() {
+	client.Mutex.Lock()
+	defer client.Mutex.Unlock()
+
+	client.disconnect()
+}
+
+func (client *RCONClient) disconnect() {
+	if client.Connection == nil {
+		return
+	}
+
+	client.Connection.Close()
+	client.Connection = nil
+}
+
+func makeFeedbackTab() ui.Control {
+	vContainer := ui.NewVerticalBox()
+	vContainer.SetPadded(true)
+
+	feedbackForm := ui.NewForm()
+	feedbackForm.SetPadded(true)
+
+	modesComboBox := ui.NewCombobox()
+
+	for _, item := range feedbackModesName {
+		modesComboBox.Append(item)
+	}
+
+	modesComboBox.SetSelected(g_appSettings.FeedbackMode)
+	modesComboBox.OnSelected(func(c *ui.Combobox) {
+		selectedItem := c.Selected()
+
+		if selectedItem == g_appSettings.FeedbackMode {
+			return
+		}
+
+		g_appSettings.FeedbackMode = selectedItem
+
+		go trySaveSettings()
+	})
+
+	feedbackForm.Append("Feedback mode :", modesComboBox, false)
+
+	rconAddressGrid := ui.NewGrid()
+	rconAddressGrid.SetPadded(true)
+
+	rconAddressEntry := ui.NewEntry()
+	rconAddressEntry.SetText(g_appSettings.RCONAddress)
+
+	rconAddressGrid.Append(rconAddressEntry, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	rconAddressButton := ui.NewButton("Apply new RCON address")
+	rconAddressButton.OnClicked(func(b *ui.Button) {
+		newAddress := strings.TrimSpace(rconAddressEntry.Text())
+
+		if newAddress == g_appSettings.RCONAddress {
+			return
+		}
+
+		if _, _, splitError := net.SplitHostPort(newAddress); splitError != nil {
+			logToEntry(splitError.Error())
+
+			rconAddressEntry.SetText(g_appSettings.RCONAddress)
+
+			return
+		}
+
+		g_appSettings.RCONAddress = newAddress
+
+		go trySaveSettings()
+	})
+
+	rconAddressGrid.Append(rconAddressButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	feedbackForm.Append("RCON address :", rconAddressGrid, false)
+
+	rconPasswordGrid := ui.NewGrid()
+	rconPasswordGrid.SetPadded(true)
+
+	rconPasswordEntry := ui.NewPasswordEntry()
+	rconPasswordEntry.SetText(g_appSettings.RCONPassword)
+
+	rconPasswordGrid.Append(rconPasswordEntry, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	rconPasswordButton := ui.NewButton("Apply new RCON password")
+	rconPasswordButton.OnClicked(func(b *ui.Button) {
+		newPassword := rconPasswordEntry.Text()
+
+		if newPassword == g_appSettings.RCONPassword {
+			return
+		}
+
+		g_appSettings.RCONPassword = newPassword
+
+		go trySaveSettings()
+	})
+
+	rconPasswordGrid.Append(rconPasswordButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	feedbackForm.Append("RCON password :", rconPasswordGrid, false)
+
+	feedbackFileGrid := ui.NewGrid()
+	feedbackFileGrid.SetPadded(true)
+
+	feedbackFileEntry := ui.NewEntry()
+	feedbackFileEntry.SetReadOnly(true)
+	feedbackFileEntry.SetText(g_appSettings.FeedbackFile)
+
+	feedbackFileGrid.Append(feedbackFileEntry, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	feedbackFileButton := ui.NewButton("Set command file")
+	feedbackFileButton.OnClicked(func(b *ui.Button) {
+		fileSave := ui.SaveFile(g_mainWindow)
+
+		if fileSave == "" {
+			return
+		}
+
+		fileSave = filepath.ToSlash(fileSave)
+
+		if fileSave == g_appSettings.FeedbackFile {
+			logToEntry("Ignored same command file")
+
+			return
+		}
+
+		if fileSave == g_appSettings.LogWatch || fileSave == g_appSettings.LogFile {
+			logToEntry("Cannot use a log file as the command file")
+
+			return
+		}
+
+		g_appSettings.FeedbackFile = fileSave
+		feedbackFileEntry.SetText(g_appSettings.FeedbackFile)
+
+		go trySaveSettings()
+	})
+
+	feedbackFileGrid.Append(feedbackFileButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	feedbackForm.Append("Command file :", feedbackFileGrid, false)
+
+	repliesGroup := ui.NewGroup("Reply templates")
+	repliesGroup.SetMargined(true)
+
+	g_replyModel = ui.NewTableModel(&ReplyTableModel{})
+	repliesTable := ui.NewTable(&ui.TableParams{
+		Model:                         g_replyModel,
+		RowBackgroundColorModelColumn: 3,
+	})
+	g_replyModel.RowInserted(0)
+
+	repliesTable.AppendTextColumn("Event", 0, ui.TableModelColumnNeverEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	repliesTable.AppendTextColumn("Template", 1, ui.TableModelColumnAlwaysEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	repliesTable.AppendButtonColumn("Reset", 2, ui.TableModelColumnAlwaysEditable)
+
+	repliesGroup.SetChild(repliesTable)
+
+	buttonBox := ui.NewHorizontalBox()
+	buttonBox.SetPadded(true)
+
+	testButton := ui.NewButton("Send test reply")
+	testButton.OnClicked(func(b *ui.Button) {
+		if g_appSettings.FeedbackMode == feedbackNone {
+			logToEntry("Feedback is disabled")
+
+			return
+		}
+
+		queueReply(appName + " feedback test")
+	})
+
+	buttonBox.Append(testButton, false)
+
+	placeholdersButton := ui.NewButton("Placeholders")
+	placeholdersButton.OnClicked(func(b *ui.Button) {
+		ui.MsgBox(g_mainWindow, "Template placeholders", replyHelpMessage)
+	})
+
+	buttonBox.Append(placeholdersButton, false)
+
+	vContainer.Append(feedbackForm, false)
+	vContainer.Append(ui.NewHorizontalSeparator(), false)
+	vContainer.Append(ui.NewLabel("Bind a key to \"exec <command file>\" (without quotes) to send command file replies.\r\n"+
+		"RCON requires the game to be launched with -usercon and a rcon_password.\r\n"+
+		"Empty templates are not sent."), false)
+	vContainer.Append(repliesGroup, true)
+	vContainer.Append(buttonBox, false)
+
+	return vContainer
+}
+
+func (mh *ReplyTableModel) ColumnTypes(m *ui.TableModel) []ui.TableValue {
+	return []ui.TableValue{
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableColor{},
+	}
+}
+
+func (mh *ReplyTableModel) NumRows(m *ui.TableModel) int {
+	return len(replyKeysList) - 1
+}
+
+func (mh *ReplyTableModel) CellValue(m *ui.TableModel, row, column int) ui.TableValue {
+	switch column {
+	case 0:
+		return ui.TableString(replyKeysList[row])
+	case 1:
+		return ui.TableString(getReplyTemplate(replyKeysList[row]))
+	case 2:
+		return ui.TableString("Reset")
+	case 3:
+		if row%2 == 0 {
+			return ui.TableColor{R: 0, G: 0, B: 0, A: 0.05}
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+func (mh *ReplyTableModel) SetCellValue(m *ui.TableModel, row, column int, value ui.TableValue) {
+	key := replyKeysList[row]
+
+	if value == nil {
+		switch column {
+		case 2:
+			if _, exists := g_appSettings.ReplyTemplates[key]; !exists {
+				return
+			}
+
+			delete(g_appSettings.ReplyTemplates, key)
+			m.RowChanged(row)
+
+			go trySaveSettings()
+		}
+
+		return
+	}
+
+	switch column {
+	case 1:
+		newTemplate := strings.TrimSpace(string(value.(ui.TableString)))
+
+		if newTemplate == getReplyTemplate(key) {
+			return
+		}
+
+		if newTemplate == defaultReplyTemplates[key] {
+			delete(g_appSettings.ReplyTemplates, key)
+		} else {
+			g_appSettings.ReplyTemplates[key] = newTemplate
+		}
+
+		go trySaveSettings()
+	}
+}
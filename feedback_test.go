@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// fakeRCONServer answers like a Source server: split responses, mirrored end marker and a trailing packet.
+
+type fakeRCONServer struct {
+	Listener    net.Listener
+	Password    string
+	DropAfter   int
+	Commands    []string
+	Connections int
+	Mutex       sync.Mutex
+}
+
+func startFakeRCONServer(t *testing.T, password string) *fakeRCONServer {
+	listener, listenError := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenError != nil {
+		t.Fatal(listenError)
+	}
+
+	server := &fakeRCONServer{Listener: listener, Password: password}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			connection, acceptError := listener.Accept()
+
+			if acceptError != nil {
+				return
+			}
+
+			go server.serve(connection)
+		}
+	}()
+
+	return server
+}
+
+func (server *fakeRCONServer) Address() string {
+	return server.Listener.Addr().String()
+}
+
+func writeFakeRCONPacket(connection net.Conn, requestID int32, packetType int32, body string) {
+	packet := make([]byte, 14+len(body))
+	binary.LittleEndian.PutUint32(packet[0:], uint32(10+len(body)))
+	binary.LittleEndian.PutUint32(packet[4:], uint32(requestID))
+	binary.LittleEndian.PutUint32(packet[8:], uint32(packetType))
+	copy(packet[12:], body)
+
+	connection.Write(packet)
+}
+
+func (server *fakeRCONServer) serve(connection net.Conn) {
+	defer connection.Close()
+
+	server.Mutex.Lock()
+	server.Connections++
+	server.Mutex.Unlock()
+
+	executed := 0
+
+	for {
+		var header [12]byte
+
+		if _, readError := io.ReadFull(connection, header[:]); readError != nil {
+			return
+		}
+
+		body := make([]byte, binary.LittleEndian.Uint32(header[0:])-8)
+
+		if _, readError := io.ReadFull(connection, body); readError != nil {
+			return
+		}
+
+		requestID := int32(binary.LittleEndian.Uint32(header[4:]))
+		bodyText := strings.TrimRight(string(body), "\x00")
+
+		switch int32(binary.LittleEndian.Uint32(header[8:])) {
+		case rconAuth:
+			writeFakeRCONPacket(connection, requestID, rconResponseValue, "")
+
+			if bodyText != server.Password {
+				writeFakeRCONPacket(connection, -1, rconAuthResponse, "")
+
+				continue
+			}
+
+			writeFakeRCONPacket(connection, requestID, rconAuthResponse, "")
+		case rconExecCommand:
+			server.Mutex.Lock()
+			server.Commands = append(server.Commands, bodyText)
+			server.Mutex.Unlock()
+
+			writeFakeRCONPacket(connection, requestID, rconResponseValue, "echo: ")
+			writeFakeRCONPacket(connection, requestID, rconResponseValue, bodyText)
+
+			executed++
+		case rconResponseValue:
+			writeFakeRCONPacket(connection, requestID, rconResponseValue, "")
+			writeFakeRCONPacket(connection, requestID, rconResponseValue, "\x00\x00\x00\x01")
+
+			if server.DropAfter != 0 && executed >= server.DropAfter {
+				return
+			}
+		}
+	}
+}
+
+func TestRCONAuthentication(t *testing.T) {
+	server := startFakeRCONServer(t, "secret")
+
+	client := &RCONClient{Address: server.Address(), Password: "secret"}
+
+	if connectError := client.Connect(); connectError != nil {
+		t.Fatalf("Connect with the right password : %v", connectError)
+	}
+
+	client.Close()
+
+	client = &RCONClient{Address: server.Address(), Password: "wrong"}
+
+	if connectError := client.Connect(); connectError == nil || connectError.Error() != "RCON : Authentication failed" {
+		t.Fatalf("Connect with a wrong password returned %v", connectError)
+	}
+
+	if client.Connection != nil {
+		t.Fatal("Failed authentication left the connection open")
+	}
+}
+
+func TestRCONExecute(t *testing.T) {
+	server := startFakeRCONServer(t, "secret")
+
+	client := &RCONClient{Address: server.Address(), Password: "secret"}
+	defer client.Close()
+
+	// The second command reads past the trailing packet of the first end marker.
+
+	for _, command := range []string{"status", "say hello"} {
+		response, executeError := client.Execute(command)
+
+		if executeError != nil {
+			t.Fatalf("Execute(%q) : %v", command, executeError)
+		}
+
+		if response != "echo: "+command {
+			t.Fatalf("Execute(%q) returned %q, want the split packets joined", command, response)
+		}
+	}
+
+	server.Mutex.Lock()
+	defer server.Mutex.Unlock()
+
+	if server.Connections != 1 {
+		t.Fatalf("Execute opened %d connections, want 1", server.Connections)
+	}
+}
+
+func TestSendRCONReplyReconnects(t *testing.T) {
+	server := startFakeRCONServer(t, "secret")
+	server.DropAfter = 1
+
+	previousSettings := g_appSettings
+
+	g_appSettings.RCONAddress = server.Address()
+	g_appSettings.RCONPassword = "secret"
+	cleanFeedback()
+
+	defer func() {
+		cleanFeedback()
+		g_appSettings = previousSettings
+	}()
+
+	for _, replyText := range []string{"first", "second"} {
+		if replyError := sendRCONReply(replyText); replyError != nil {
+			t.Fatalf("sendRCONReply(%q) : %v", replyText, replyError)
+		}
+	}
+
+	server.Mutex.Lock()
+	defer server.Mutex.Unlock()
+
+	if strings.Join(server.Commands, "|") != `say "first"|say "second"` {
+		t.Fatalf("Server received %q", server.Commands)
+	}
+
+	if server.Connections != 2 {
+		t.Fatalf("Server saw %d connections, want a new one after the drop", server.Connections)
+	}
+}
+
+func TestTruncateReply(t *testing.T) {
+	shortText := "Playing #12: airhorn"
+
+	if truncateReply(shortText) != shortText {
+		t.Fatalf("truncateReply changed a short reply to %q", truncateReply(shortText))
+	}
+
+	// "é" takes two bytes, so the limit falls inside the last one.
+
+	longText := strings.Repeat("a", maxReplyLength-1) + "é"
+	truncatedText := truncateReply(longText)
+
+	if !utf8.ValidString(truncatedText) || truncatedText != strings.Repeat("a", maxReplyLength-1) {
+		t.Fatalf("truncateReply split a character : %q", truncatedText)
+	}
+}
+
+func TestReplySanitizer(t *testing.T) {
+	testCases := []struct {
+		Text   string
+		Result string
+	}{
+		{"Playing #12: airhorn", "Playing #12: airhorn"},
+		{`a"; quit; "`, "a', quit, '"},
+		{"first\r\nsecond", "first  second"},
+		{"line\nbreak", "line break"},
+		{"it's fine", "it's fine"},
+	}
+
+	for _, testCase := range testCases {
+		if result := replySanitizer.Replace(testCase.Text); result != testCase.Result {
+			t.Errorf("replySanitizer changed %q to %q, want %q", testCase.Text, result, testCase.Result)
+		}
+	}
+}
+
+func TestFormatReply(t *testing.T) {
+	previousTemplates := g_appSettings.ReplyTemplates
+
+	defer func() { g_appSettings.ReplyTemplates = previousTemplates }()
+
+	g_appSettings.ReplyTemplates = map[string]string{
+		"play.notfound": "  {user} asked for {arg}  ",
+		"skip.skipped":  "",
+		"custom":        "{user}: {count} of {limit}",
+	}
+
+	testCases := []struct {
+		Key          string
+		Replacements []string
+		Result       string
+	}{
+		{"play.queued", []string{"{user}", "Scout", "{id}", "12", "{name}", "airhorn", "{position}", "3"}, "Queued #12: airhorn (position 3)"},
+		{"play.notfound", []string{"{user}", "Scout", "{arg}", "horn"}, "Scout asked for horn"},
+		{"play.notfound", []string{"{user}", `Spy";quit;"`, "{arg}", "x\ndisconnect"}, "Spy',quit,' asked for x disconnect"},
+		{"custom", []string{"{user}", "Scout", "{count}", "{limit}", "{limit}", "5"}, "Scout: {limit} of 5"},
+		{"custom", nil, "{user}: {count} of {limit}"},
+		{"skip.skipped", []string{"{name}", "airhorn"}, ""},
+		{"unknown", []string{"{user}", "Scout"}, ""},
+		{"play.notfound", []string{"{user}", "Scout", "{arg}", strings.Repeat("é", maxReplyLength)}, truncateReply("Scout asked for " + strings.Repeat("é", maxReplyLength))},
+	}
+
+	for _, testCase := range testCases {
+		if result := formatReply(testCase.Key, testCase.Replacements...); result != testCase.Result {
+			t.Errorf("formatReply(%q, %q) returned %q, want %q", testCase.Key, testCase.Replacements, result, testCase.Result)
+		}
+	}
+}
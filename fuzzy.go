@@ -0,0 +1,360 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type TrackIndex struct {
+	Entries  []*IndexEntry
+	Tracks   map[*AudioTrack]*IndexEntry
+	Trigrams map[string][]int
+}
+
+type IndexEntry struct {
+	Track      *AudioTrack
+	Normalized string
+	Tokens     []string
+}
+
+type FuzzyMatch struct {
+	Track *AudioTrack
+	Score float64
+}
+
+const defaultFuzzyThreshold float32 = 0.6
+const ambiguityMargin = 0.05
+const maxFuzzyCandidates = 256
+const maxSuggestions = 3
+
+var g_trackIndex *TrackIndex
+
+func normalizeName(name string) string {
+	var builder strings.Builder
+
+	lastSpace := true
+
+	for _, character := range strings.ToLower(name) {
+		if unicode.IsLetter(character) || unicode.IsDigit(character) {
+			builder.WriteRune(character)
+
+			lastSpace = false
+
+			continue
+		}
+
+		if lastSpace {
+			continue
+		}
+
+		builder.WriteByte(' ')
+
+		lastSpace = true
+	}
+
+	return strings.TrimSpace(builder.String())
+}
+
+func makeTrigrams(normalized string) []string {
+	padded := []rune(" " + normalized + " ")
+
+	if len(padded) < 3 {
+		return nil
+	}
+
+	trigrams := make([]string, 0, len(padded)-2)
+	seen := make(map[string]bool, len(padded)-2)
+
+	for index := 0; index+3 <= len(padded); index++ {
+		trigram := string(padded[index : index+3])
+
+		if seen[trigram] {
+			continue
+		}
+
+		seen[trigram] = true
+		trigrams = append(trigrams, trigram)
+	}
+
+	return trigrams
+}
+
+func NewTrackIndex(tracks []*AudioTrack) *TrackIndex {
+	index := &TrackIndex{
+		Entries:  make([]*IndexEntry, 0, len(tracks)),
+		Tracks:   make(map[*AudioTrack]*IndexEntry, len(tracks)),
+		Trigrams: make(map[string][]int),
+	}
+
+	for _, item := range tracks {
+		index.Add(item)
+	}
+
+	return index
+}
+
+func (index *TrackIndex) Add(track *AudioTrack) {
+	normalized := normalizeName(track.Name)
+	entryID := len(index.Entries)
+
+	entry := &IndexEntry{
+		Track:      track,
+		Normalized: normalized,
+		Tokens:     strings.Fields(normalized),
+	}
+
+	index.Entries = append(index.Entries, entry)
+	index.Tracks[track] = entry
+
+	for _, trigram := range makeTrigrams(normalized) {
+		index.Trigrams[trigram] = append(index.Trigrams[trigram], entryID)
+	}
+}
+
+// Search returns the matches scoring at least threshold, best first.
+// Only the entries sharing the most trigrams with the query are scored, so large libraries stay fast.
+
+func (index *TrackIndex) Search(query string, threshold float64) []FuzzyMatch {
+	normalized := normalizeName(query)
+
+	if normalized == "" {
+		return nil
+	}
+
+	queryTokens := strings.Fields(normalized)
+
+	var candidates []int
+
+	if queryTrigrams := makeTrigrams(normalized); len(queryTrigrams) > 2 {
+		sharedCount := make(map[int]int)
+
+		for _, trigram := range queryTrigrams {
+			for _, entryID := range index.Trigrams[trigram] {
+				sharedCount[entryID]++
+			}
+		}
+
+		candidates = make([]int, 0, len(sharedCount))
+
+		for entryID := range sharedCount {
+			candidates = append(candidates, entryID)
+		}
+
+		// Ties are broken by label, so the same tracks are kept whatever the map order
+
+		if len(candidates) > maxFuzzyCandidates {
+			sort.Slice(candidates, func(i, j int) bool {
+				if sharedCount[candidates[i]] != sharedCount[candidates[j]] {
+					return sharedCount[candidates[i]] > sharedCount[candidates[j]]
+				}
+
+				return isTrackBefore(index.Entries[candidates[i]].Track, index.Entries[candidates[j]].Track)
+			})
+
+			candidates = candidates[:maxFuzzyCandidates]
+		}
+	} else {
+		candidates = make([]int, len(index.Entries))
+
+		for entryID := range candidates {
+			candidates[entryID] = entryID
+		}
+	}
+
+	var matches []FuzzyMatch
+
+	for _, entryID := range candidates {
+		entry := index.Entries[entryID]
+		score := scoreEntry(normalized, queryTokens, entry)
+
+		if score < threshold {
+			continue
+		}
+
+		matches = append(matches, FuzzyMatch{entry.Track, score})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+
+		return isTrackBefore(matches[i].Track, matches[j].Track)
+	})
+
+	return matches
+}
+
+// isTrackBefore orders equally close tracks by label, then by path for removed tracks sharing the label.
+
+func isTrackBefore(first *AudioTrack, second *AudioTrack) bool {
+	firstLabel, secondLabel := first.Label(), second.Label()
+
+	if firstLabel != secondLabel {
+		return firstLabel < secondLabel
+	}
+
+	return first.Path < second.Path
+}
+
+func scoreEntry(query string, queryTokens []string, entry *IndexEntry) float64 {
+	if entry.Normalized == query {
+		return 1
+	}
+
+	if entry.Normalized == "" {
+		return 0
+	}
+
+	score := similarity(query, entry.Normalized)
+
+	if strings.Contains(entry.Normalized, query) {
+		score = math.Max(score, 0.85+0.1*float64(len(query))/float64(len(entry.Normalized)))
+	}
+
+	var tokensScore float64
+
+	for _, queryToken := range queryTokens {
+		var bestScore float64
+
+		for _, token := range entry.Tokens {
+			tokenScore := similarity(queryToken, token)
+
+			if strings.HasPrefix(token, queryToken) {
+				tokenScore = math.Max(tokenScore, 0.8+0.15*float64(len(queryToken))/float64(len(token)))
+			}
+
+			bestScore = math.Max(bestScore, tokenScore)
+		}
+
+		tokensScore += bestScore
+	}
+
+	tokensScore /= float64(len(queryTokens))
+
+	// Exact matches are the only ones allowed to reach 1
+
+	return math.Min(math.Max(score, tokensScore*0.95), 0.99)
+}
+
+func similarity(first string, second string) float64 {
+	firstRunes, secondRunes := []rune(first), []rune(second)
+	longest := len(firstRunes)
+
+	if len(secondRunes) > longest {
+		longest = len(secondRunes)
+	}
+
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein(firstRunes, secondRunes))/float64(longest)
+}
+
+func levenshtein(first []rune, second []rune) int {
+	if len(first) < len(second) {
+		first, second = second, first
+	}
+
+	previousRow := make([]int, len(second)+1)
+	currentRow := make([]int, len(second)+1)
+
+	for index := range previousRow {
+		previousRow[index] = index
+	}
+
+	for firstIndex := 1; firstIndex <= len(first); firstIndex++ {
+		currentRow[0] = firstIndex
+
+		for secondIndex := 1; secondIndex <= len(second); secondIndex++ {
+			cost := 1
+
+			if first[firstIndex-1] == second[secondIndex-1] {
+				cost = 0
+			}
+
+			currentRow[secondIndex] = minInt(minInt(previousRow[secondIndex]+1, currentRow[secondIndex-1]+1),
+				previousRow[secondIndex-1]+cost)
+		}
+
+		previousRow, currentRow = currentRow, previousRow
+	}
+
+	return previousRow[len(second)]
+}
+
+func minInt(first int, second int) int {
+	if first < second {
+		return first
+	}
+
+	return second
+}
+
+func getIndexEntry(track *AudioTrack) *IndexEntry {
+	if trackIndex := g_trackIndex; trackIndex != nil {
+		if entry, exists := trackIndex.Tracks[track]; exists {
+			return entry
+		}
+	}
+
+	normalized := normalizeName(track.Name)
+
+	return &IndexEntry{track, normalized, strings.Fields(normalized)}
+}
+
+func rebuildTrackIndex() {
+	g_trackIndex = NewTrackIndex(g_tracksList)
+}
+
+// fuzzyFindTrack returns the best match, or the closest candidates when the match is ambiguous.
+// Several tracks with the same name are ambiguous too, and only those are suggested.
+
+func fuzzyFindTrack(arg string) (*AudioTrack, []*AudioTrack) {
+	trackIndex := g_trackIndex
+
+	if trackIndex == nil {
+		return nil, nil
+	}
+
+	matches := trackIndex.Search(arg, float64(g_appSettings.FuzzyThreshold))
+
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	margin := ambiguityMargin
+
+	if matches[0].Score == 1 {
+		margin = 0
+	}
+
+	if len(matches) == 1 || matches[0].Score-matches[1].Score > margin {
+		return matches[0].Track, nil
+	}
+
+	var suggestions []*AudioTrack
+
+	for _, item := range matches {
+		if len(suggestions) == maxSuggestions || matches[0].Score-item.Score > margin {
+			break
+		}
+
+		suggestions = append(suggestions, item.Track)
+	}
+
+	return nil, suggestions
+}
+
+func formatSuggestions(tracks []*AudioTrack) string {
+	suggestions := make([]string, len(tracks))
+
+	for index, item := range tracks {
+		suggestions[index] = "#" + item.Label() + " " + item.Name
+	}
+
+	return strings.Join(suggestions, ", ")
+}
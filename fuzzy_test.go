@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func makeFuzzyTracks(names ...string) []*AudioTrack {
+	tracks := make([]*AudioTrack, len(names))
+
+	for index, item := range names {
+		tracks[index] = &AudioTrack{ID: index + 1, Name: item}
+	}
+
+	return tracks
+}
+
+func TestTrackIndexSearch(t *testing.T) {
+	trackIndex := NewTrackIndex(makeFuzzyTracks(
+		"Airhorn",
+		"Air raid siren",
+		"Victory fanfare",
+		"victory_royale",
+		"Sad trombone",
+		"Headshot",
+	))
+
+	testCases := []struct {
+		Query string
+		Best  string
+		Exact bool
+	}{
+		{"airhorn", "Airhorn", true},
+		{"AIRHORN!!", "Airhorn", true},
+		{"airhron", "Airhorn", false},
+		{"trombnoe", "Sad trombone", false},
+		{"siren", "Air raid siren", false},
+		{"headsh", "Headshot", false},
+		{"victory royale", "victory_royale", true},
+	}
+
+	for _, testCase := range testCases {
+		matches := trackIndex.Search(testCase.Query, float64(defaultFuzzyThreshold))
+
+		if len(matches) == 0 {
+			t.Errorf("Search(%q) found nothing, want %q", testCase.Query, testCase.Best)
+
+			continue
+		}
+
+		if matches[0].Track.Name != testCase.Best {
+			t.Errorf("Search(%q) ranked %q first, want %q", testCase.Query, matches[0].Track.Name, testCase.Best)
+		}
+
+		if (matches[0].Score == 1) != testCase.Exact {
+			t.Errorf("Search(%q) scored %q %.2f, exact match expected : %v", testCase.Query, matches[0].Track.Name, matches[0].Score, testCase.Exact)
+		}
+	}
+}
+
+func TestTrackIndexThreshold(t *testing.T) {
+	trackIndex := NewTrackIndex(makeFuzzyTracks("Airhorn", "Headshot"))
+
+	if matches := trackIndex.Search("xylophone", float64(defaultFuzzyThreshold)); len(matches) != 0 {
+		t.Fatalf("Search(\"xylophone\") matched %q below the threshold", matches[0].Track.Name)
+	}
+
+	if matches := trackIndex.Search("!!!", 0); matches != nil {
+		t.Fatalf("Search of an empty normalized query returned %d matches", len(matches))
+	}
+
+	for _, item := range trackIndex.Search("airhorm", 0) {
+		if item.Score < 0 || item.Score > 0.99 {
+			t.Fatalf("Inexact match %q scored %.2f, outside 0-0.99", item.Track.Name, item.Score)
+		}
+	}
+}
+
+func TestFuzzyFindTrackAmbiguity(t *testing.T) {
+	previousIndex, previousThreshold := g_trackIndex, g_appSettings.FuzzyThreshold
+
+	defer func() {
+		g_trackIndex, g_appSettings.FuzzyThreshold = previousIndex, previousThreshold
+	}()
+
+	g_appSettings.FuzzyThreshold = defaultFuzzyThreshold
+	g_trackIndex = NewTrackIndex(makeFuzzyTracks("Horn one", "Horn two", "Horn three", "Horn four", "Trombone"))
+
+	track, suggestions := fuzzyFindTrack("horn")
+
+	if track != nil {
+		t.Fatalf("fuzzyFindTrack(\"horn\") picked %q among equally close tracks", track.Name)
+	}
+
+	if len(suggestions) != maxSuggestions {
+		t.Fatalf("fuzzyFindTrack(\"horn\") suggested %d tracks, want %d", len(suggestions), maxSuggestions)
+	}
+
+	for _, item := range suggestions {
+		if item.Name == "Trombone" {
+			t.Fatal("fuzzyFindTrack(\"horn\") suggested a track outside the ambiguity margin")
+		}
+	}
+
+	if track, suggestions = fuzzyFindTrack("horn two"); track == nil || track.Name != "Horn two" || suggestions != nil {
+		t.Fatalf("fuzzyFindTrack(\"horn two\") returned %v and %d suggestions, want the exact match", track, len(suggestions))
+	}
+
+	if track, _ = fuzzyFindTrack("trombnoe"); track == nil || track.Name != "Trombone" {
+		t.Fatalf("fuzzyFindTrack(\"trombnoe\") returned %v, want Trombone", track)
+	}
+}
+
+func TestFuzzyFindTrackExactDuplicates(t *testing.T) {
+	previousIndex, previousThreshold := g_trackIndex, g_appSettings.FuzzyThreshold
+
+	defer func() {
+		g_trackIndex, g_appSettings.FuzzyThreshold = previousIndex, previousThreshold
+	}()
+
+	// The same file in two roots, with IDs from different sequences
+
+	tracks := makeFuzzyTracks("Airhorn", "Airhorn!", "Airhorn two", "Airhorns")
+	tracks[1].ID = 1
+	tracks[1].Root = &LibraryRoot{Prefix: "p"}
+
+	g_appSettings.FuzzyThreshold = defaultFuzzyThreshold
+	g_trackIndex = NewTrackIndex(tracks)
+
+	track, suggestions := fuzzyFindTrack("airhorn")
+
+	if track != nil {
+		t.Fatalf("fuzzyFindTrack(\"airhorn\") picked %q among tracks with the same name", track.Label())
+	}
+
+	if len(suggestions) != 2 || suggestions[0] != tracks[0] || suggestions[1] != tracks[1] {
+		t.Fatalf("fuzzyFindTrack(\"airhorn\") suggested %q, want both exact matches", formatSuggestions(suggestions))
+	}
+
+	if track, suggestions = fuzzyFindTrack("airhorn two"); track != tracks[2] || suggestions != nil {
+		t.Fatalf("fuzzyFindTrack(\"airhorn two\") returned %v and %d suggestions, want the single exact match", track, len(suggestions))
+	}
+}
+
+func TestTrackIndexCandidatesOrder(t *testing.T) {
+	names := make([]string, maxFuzzyCandidates+50)
+
+	for index := range names {
+		names[index] = fmt.Sprintf("Airhorn %c%c", 'a'+index/26, 'a'+index%26)
+	}
+
+	tracks := makeFuzzyTracks(names...)
+	trackIndex := NewTrackIndex(tracks)
+
+	// Every track shares the same trigrams with the query, so the cut depends on the tie-break only
+
+	firstMatches := trackIndex.Search("airhorn", 0)
+
+	if len(firstMatches) != maxFuzzyCandidates {
+		t.Fatalf("Search kept %d candidates, want %d", len(firstMatches), maxFuzzyCandidates)
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		matches := trackIndex.Search("airhorn", 0)
+
+		for index, item := range matches {
+			if item.Track != firstMatches[index].Track {
+				t.Fatalf("Search ranked %q at %d, then %q", firstMatches[index].Track.Name, index, item.Track.Name)
+			}
+		}
+	}
+
+	for index := 1; index < len(firstMatches); index++ {
+		previous, current := firstMatches[index-1], firstMatches[index]
+
+		if previous.Score == current.Score && previous.Track.Label() > current.Track.Label() {
+			t.Fatalf("Search ranked #%s before #%s with the same score", previous.Track.Label(), current.Track.Label())
+		}
+	}
+}
+
+// makeBenchmarkIndex names tracks from a few word lists, so many of them share trigrams like real libraries.
+
+func makeBenchmarkIndex(trackCount int) *TrackIndex {
+	firstWords := []string{"air", "victory", "sad", "epic", "bass", "drum", "crowd", "laser", "alarm", "meme"}
+	secondWords := []string{"horn", "fanfare", "trombone", "drop", "roll", "cheer", "blast", "siren", "remix", "loop"}
+	randomSource := rand.New(rand.NewSource(1))
+
+	names := make([]string, trackCount)
+
+	for index := range names {
+		names[index] = fmt.Sprintf("%s %s %d", firstWords[randomSource.Intn(len(firstWords))],
+			secondWords[randomSource.Intn(len(secondWords))], index)
+	}
+
+	return NewTrackIndex(makeFuzzyTracks(names...))
+}
+
+func BenchmarkSearch(b *testing.B) {
+	for _, trackCount := range []int{20000, 50000} {
+		trackIndex := makeBenchmarkIndex(trackCount)
+
+		for _, query := range []string{"airhron", "victory fanfare 4242", "ep"} {
+			b.Run(fmt.Sprintf("%d/%s", trackCount, query), func(b *testing.B) {
+				for index := 0; index < b.N; index++ {
+					trackIndex.Search(query, float64(defaultFuzzyThreshold))
+				}
+			})
+		}
+	}
+}
@@ -4,6 +4,7 @@ go 1.20
 
 require (
 	github.com/bep/debounce v1.2.1
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/gen2brain/malgo v0.11.10
 	github.com/lxn/win v0.0.0-20210218163916-a377121e959e
 	github.com/moutend/go-hook v0.1.0
@@ -11,7 +12,6 @@ require (
 )
 
 require (
-	github.com/fsnotify/fsnotify v1.6.0 // indirect
 	golang.org/x/sys v0.7.0 // indirect
 	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
 )
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"waveboard/fixes/ui"
+
+	"github.com/moutend/go-hook/pkg/types"
+)
+
+// Global hotkeys share g_appSettings.TransportKeys with the playback ones and run before macros and track bindings.
+
+const defaultVolumeStep float32 = 10.0
+
+// g_outputMuted silences DataFunc without touching the global volume, so it is not saved.
+
+var g_outputMuted atomic.Bool
+
+// g_lastTrack is the last library track which was played, set under g_stopMutex.
+
+var g_lastTrack *AudioTrack
+
+func formatKey(key types.VKCode) string {
+	return strings.ReplaceAll(key.String(), "VK_", "")
+}
+
+// stopAll cancels macros and delayed actions first, so they cannot queue tracks again,
+// then clears the queue and skips the current track, returning the removed entries count.
+
+func stopAll() int {
+	cancelMacros()
+	g_scheduler.Clear()
+
+	removedEntries := g_audioQueue.Clear()
+
+	releaseEntries(removedEntries)
+	refreshQueue()
+
+	skipCurrentTrack()
+
+	return len(removedEntries)
+}
+
+func clearQueue() {
+	releaseEntries(g_audioQueue.Clear())
+	refreshQueue()
+}
+
+func replayLastTrack() {
+	g_stopMutex.Lock()
+	track := g_lastTrack
+	g_stopMutex.Unlock()
+
+	if track == nil {
+		return
+	}
+
+	if findTrackByPath(track.Path) != track {
+		ui.QueueMain(func() { logToEntry("Cannot replay %s, it is no longer in a library", track.Name) })
+
+		return
+	}
+
+	tryPlaySound(track, g_selectedDevice)
+}
+
+func stepGlobalVolume(step float32) {
+	newGlobalVolume := g_appSettings.GlobalVolume + step
+
+	if newGlobalVolume < 0 {
+		newGlobalVolume = 0
+	}
+
+	setGlobalVolume(newGlobalVolume)
+}
+
+func setGlobalVolume(newGlobalVolume float32) {
+	if newGlobalVolume == g_appSettings.GlobalVolume {
+		return
+	}
+
+	g_appSettings.GlobalVolume = newGlobalVolume
+
+	ui.QueueMain(func() {
+		g_globalVolumeEntry.SetText(strconv.FormatFloat(float64(g_appSettings.GlobalVolume), 'f', 2, 32))
+	})
+
+	go trySaveSettings()
+}
+
+func toggleMute() {
+	muted := !g_outputMuted.Load()
+
+	g_outputMuted.Store(muted)
+
+	ui.QueueMain(func() {
+		if muted {
+			logToEntry("Output muted")
+		} else {
+			logToEntry("Output unmuted")
+		}
+	})
+}
+
+// reportHotkeyConflicts lists the hotkeys which were also saved as track bindings, the hotkeys winning.
+
+func reportHotkeyConflicts() {
+	for _, action := range transportActionsList {
+		key, bound := g_appSettings.TransportKeys[action]
+
+		if !bound {
+			continue
+		}
+
+		if key == deleteKey {
+			logToEntry("The %s hotkey cannot use %s, which unbinds keys", strings.ToLower(transportActionNames[action]), formatKey(key))
+
+			continue
+		}
+
+		for _, track := range g_tracksList {
+			if track.Binding != key {
+				continue
+			}
+
+			logToEntry("%s is bound to both the %s hotkey and #%s %s in bank %d, only the hotkey will work",
+				formatKey(key), strings.ToLower(transportActionNames[action]), track.Label(), track.Name, track.Bank+1)
+		}
+	}
+}
+
+func makeStepGrid(value float32, buttonText string, apply func(float32)) ui.Control {
+	stepGrid := ui.NewGrid()
+	stepGrid.SetPadded(true)
+
+	stepEntry := ui.NewEntry()
+	stepEntry.SetText(strconv.FormatFloat(float64(value), 'f', -1, 32))
+
+	stepGrid.Append(stepEntry, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	stepButton := ui.NewButton(buttonText)
+	stepButton.OnClicked(func(b *ui.Button) {
+		newStep, convError := strconv.ParseFloat(stepEntry.Text(), 32)
+
+		if convError != nil {
+			logToEntry(convError.Error())
+
+			return
+		}
+
+		if newStep <= 0 {
+			stepEntry.SetText(strconv.FormatFloat(float64(value), 'f', -1, 32))
+
+			logToEntry("Step must be greater than 0")
+
+			return
+		}
+
+		value = float32(newStep)
+
+		apply(value)
+
+		go trySaveSettings()
+	})
+
+	stepGrid.Append(stepButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	return stepGrid
+}
+
+func makeHotkeysTab() ui.Control {
+	vContainer := ui.NewVerticalBox()
+	vContainer.SetPadded(true)
+
+	hotkeysForm := ui.NewForm()
+	hotkeysForm.SetPadded(true)
+
+	for _, item := range transportActionsList {
+		action := item
+
+		actionButton := ui.NewButton("")
+		actionButton.OnClicked(func(b *ui.Button) {
+			if g_bindingRow != -1 {
+				g_filesTableModel.RowChanged(g_bindingRow)
+				g_bindingRow = -1
+			}
+
+			cancelTransportBinding()
+			cancelMacroBinding()
+
+			g_bindingAction = action
+
+			updateTransportButton(action)
+		})
+
+		g_transportButtons[action] = actionButton
+
+		updateTransportButton(action)
+
+		hotkeysForm.Append(transportActionNames[action]+" :", actionButton, false)
+	}
+
+	hotkeysForm.Append("Seek step :", makeStepGrid(g_appSettings.SeekStep, "Apply new seek step (s)", func(step float32) {
+		g_appSettings.SeekStep = step
+	}), false)
+
+	hotkeysForm.Append("Banks :", makeBankCountGrid(), false)
+	hotkeysForm.Append("Volume step :", makeStepGrid(g_appSettings.VolumeStep, "Apply new volume step (%)", func(step float32) {
+		g_appSettings.VolumeStep = step
+	}), false)
+
+	vContainer.Append(ui.NewLabel("Hotkeys work in any window and take their key away from tracks. Use backspace to unbind them."), false)
+	vContainer.Append(hotkeysForm, false)
+
+	reportHotkeyConflicts()
+
+	return vContainer
+}
@@ -0,0 +1,663 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"waveboard/fixes/ui"
+)
+
+// Track settings and IDs are keyed relative to their root, so moving the whole folder keeps them.
+
+type LibraryRoot struct {
+	Path     string                 `json:"path"`
+	Enabled  bool                   `json:"enabled"`
+	Volume   float32                `json:"volume"`
+	Prefix   string                 `json:"prefix"`
+	Tracks   map[string]*AudioTrack `json:"tracks"`
+	TrackIDs map[string]int         `json:"trackids"`
+}
+
+type OrphanedSettings struct {
+	Settings *AudioTrack
+	Tracks   map[string]*AudioTrack
+	TrackIDs map[string]int
+	Key      string
+}
+
+type LibrariesTableModel struct{}
+
+var g_librariesModel *ui.TableModel
+
+func NewLibraryRoot(rootPath string) *LibraryRoot {
+	return &LibraryRoot{
+		Path:     filepath.ToSlash(rootPath),
+		Enabled:  true,
+		Volume:   defaultVolume,
+		Prefix:   "",
+		Tracks:   make(map[string]*AudioTrack),
+		TrackIDs: make(map[string]int),
+	}
+}
+
+func (root *LibraryRoot) FullPath() string {
+	return filepath.FromSlash(root.Path)
+}
+
+func (root *LibraryRoot) RelativeKey(fullFilePath string) string {
+	relativePath, relError := filepath.Rel(root.FullPath(), fullFilePath)
+
+	if relError != nil {
+		return filepath.ToSlash(fullFilePath)
+	}
+
+	return filepath.ToSlash(relativePath)
+}
+
+// settingsLocation returns where the settings of a file are saved.
+// Files outside of every root fall back to the absolute path keys.
+
+func settingsLocation(root *LibraryRoot, fullFilePath string) (map[string]*AudioTrack, map[string]int, string) {
+	if root == nil {
+		return g_appSettings.Tracks, g_appSettings.TrackIDs, filepath.ToSlash(fullFilePath)
+	}
+
+	return root.Tracks, root.TrackIDs, root.RelativeKey(fullFilePath)
+}
+
+// Roots without a prefix share one ID sequence, while every prefix has its own.
+// Files outside of every root, such as downloads saved elsewhere, use the unprefixed sequence.
+
+func rootPrefixKey(root *LibraryRoot) string {
+	if root == nil {
+		return ""
+	}
+
+	return strings.ToLower(root.Prefix)
+}
+
+// IDs are never reused, so deleted files leave gaps instead of shifting the others.
+
+func nextTrackID(root *LibraryRoot) int {
+	prefixKey := rootPrefixKey(root)
+	trackID := g_appSettings.NextTrackIDs[prefixKey]
+
+	g_appSettings.NextTrackIDs[prefixKey] = trackID + 1
+
+	return trackID
+}
+
+// assignTrackID returns the saved ID of a file, or a new one when it has none or it is taken.
+// usedLabels tracks the IDs given out during a scan, otherwise the loaded tracks are searched.
+// New IDs are not saved here, so a scan saves the settings once when it is done.
+
+func assignTrackID(fullFilePath string, root *LibraryRoot, usedLabels map[string]bool) int {
+	_, trackIDs, settingsKey := settingsLocation(root, fullFilePath)
+	prefixKey := rootPrefixKey(root)
+
+	if trackID, exists := trackIDs[settingsKey]; exists {
+		label := prefixKey + strconv.Itoa(trackID)
+
+		taken := false
+
+		if usedLabels != nil {
+			taken = usedLabels[label]
+		} else if existingTrack := getTrackByLabel(label); existingTrack != nil && existingTrack.Path != fullFilePath {
+			taken = true
+		}
+
+		if !taken {
+			if trackID >= g_appSettings.NextTrackIDs[prefixKey] {
+				g_appSettings.NextTrackIDs[prefixKey] = trackID + 1
+			}
+
+			if usedLabels != nil {
+				usedLabels[label] = true
+			}
+
+			return trackID
+		}
+	}
+
+	trackID := nextTrackID(root)
+
+	for usedLabels != nil && usedLabels[prefixKey+strconv.Itoa(trackID)] {
+		trackID = nextTrackID(root)
+	}
+
+	trackIDs[settingsKey] = trackID
+
+	if usedLabels != nil {
+		usedLabels[prefixKey+strconv.Itoa(trackID)] = true
+	}
+
+	return trackID
+}
+
+// findTrackRoot returns the innermost enabled root containing the path.
+
+func findTrackRoot(fullFilePath string) *LibraryRoot {
+	return findRoot(fullFilePath, true)
+}
+
+func findRoot(fullFilePath string, enabledOnly bool) *LibraryRoot {
+	var foundRoot *LibraryRoot
+
+	for _, item := range g_appSettings.Libraries {
+		if (enabledOnly && !item.Enabled) || !isWithinPath(fullFilePath, item.FullPath()) {
+			continue
+		}
+
+		if foundRoot == nil || len(item.Path) > len(foundRoot.Path) {
+			foundRoot = item
+		}
+	}
+
+	return foundRoot
+}
+
+// getDownloadRoot returns the first enabled root, where downloaded videos are saved.
+
+func getDownloadRoot() *LibraryRoot {
+	for _, item := range g_appSettings.Libraries {
+		if item.Enabled {
+			return item
+		}
+	}
+
+	return nil
+}
+
+func (track *AudioTrack) Label() string {
+	if track.Root == nil {
+		return strconv.Itoa(track.ID)
+	}
+
+	return track.Root.Prefix + strconv.Itoa(track.ID)
+}
+
+func (track *AudioTrack) DefaultVolume() float32 {
+	if track.Root == nil {
+		return defaultVolume
+	}
+
+	return track.Root.Volume
+}
+
+// getTrackByLabel resolves "12" and prefixed IDs like "p12".
+
+func getTrackByLabel(label string) *AudioTrack {
+	for _, item := range g_tracksList {
+		if item.ID == -1 || !strings.EqualFold(item.Label(), label) {
+			continue
+		}
+
+		return item
+	}
+
+	return nil
+}
+
+func validatePrefix(prefix string) error {
+	for _, character := range prefix {
+		if character >= '0' && character <= '9' {
+			return errors.New("ID prefixes can not contain digits")
+		}
+
+		if character == ' ' {
+			return errors.New("ID prefixes can not contain spaces")
+		}
+	}
+
+	return nil
+}
+
+func addLibraryRoot(rootPath string) error {
+	newRoot := NewLibraryRoot(rootPath)
+
+	for _, item := range g_appSettings.Libraries {
+		if strings.EqualFold(item.Path, newRoot.Path) {
+			return errors.New("Ignored same audio directory")
+		}
+	}
+
+	g_appSettings.Libraries = append(g_appSettings.Libraries, newRoot)
+
+	if migratedCount := migrateTrackSettings(); migratedCount != 0 {
+		logToEntry("Restored the settings of %d tracks", migratedCount)
+	}
+
+	return nil
+}
+
+// removeLibraryRoot keeps the settings of the root under absolute keys, in case it is added back.
+
+func removeLibraryRoot(index int) {
+	root := g_appSettings.Libraries[index]
+
+	for key, value := range root.Tracks {
+		g_appSettings.Tracks[filepath.ToSlash(filepath.Join(root.FullPath(), filepath.FromSlash(key)))] = value
+	}
+
+	for key, value := range root.TrackIDs {
+		g_appSettings.TrackIDs[filepath.ToSlash(filepath.Join(root.FullPath(), filepath.FromSlash(key)))] = value
+	}
+
+	g_appSettings.Libraries = append(g_appSettings.Libraries[:index], g_appSettings.Libraries[index+1:]...)
+}
+
+// migrateTrackSettings moves the absolute path keys of older settings into the roots containing them.
+
+func migrateTrackSettings() int {
+	migratedCount := 0
+
+	for key, value := range g_appSettings.Tracks {
+		root := findRoot(filepath.FromSlash(key), false)
+
+		if root == nil {
+			continue
+		}
+
+		relativeKey := root.RelativeKey(filepath.FromSlash(key))
+
+		if _, exists := root.Tracks[relativeKey]; !exists {
+			root.Tracks[relativeKey] = value
+		}
+
+		delete(g_appSettings.Tracks, key)
+
+		migratedCount++
+	}
+
+	for key, value := range g_appSettings.TrackIDs {
+		root := findRoot(filepath.FromSlash(key), false)
+
+		if root == nil {
+			continue
+		}
+
+		relativeKey := root.RelativeKey(filepath.FromSlash(key))
+
+		if _, exists := root.TrackIDs[relativeKey]; !exists {
+			root.TrackIDs[relativeKey] = value
+		}
+
+		delete(g_appSettings.TrackIDs, key)
+	}
+
+	return migratedCount
+}
+
+// relocateLibraryRoot points a root at the folder it was moved to.
+// Relative keys carry over as they are, absolute ones left under the old path are rewritten.
+
+func relocateLibraryRoot(root *LibraryRoot, newPath string) error {
+	newRoot := NewLibraryRoot(newPath)
+
+	if strings.EqualFold(newRoot.Path, root.Path) {
+		return errors.New("Ignored same audio directory")
+	}
+
+	for _, item := range g_appSettings.Libraries {
+		if item != root && strings.EqualFold(item.Path, newRoot.Path) {
+			return errors.New("Directory is already a library")
+		}
+	}
+
+	oldPath := root.FullPath()
+	root.Path = newRoot.Path
+
+	for key, value := range g_appSettings.Tracks {
+		if !isWithinPath(filepath.FromSlash(key), oldPath) {
+			continue
+		}
+
+		relativePath, _ := filepath.Rel(oldPath, filepath.FromSlash(key))
+		root.Tracks[filepath.ToSlash(relativePath)] = value
+
+		delete(g_appSettings.Tracks, key)
+	}
+
+	for key, value := range g_appSettings.TrackIDs {
+		if !isWithinPath(filepath.FromSlash(key), oldPath) {
+			continue
+		}
+
+		relativePath, _ := filepath.Rel(oldPath, filepath.FromSlash(key))
+		root.TrackIDs[filepath.ToSlash(relativePath)] = value
+
+		delete(g_appSettings.TrackIDs, key)
+	}
+
+	return nil
+}
+
+// reattachOrphanedSettings gives the settings of missing files to loaded files with the same contents.
+
+func reattachOrphanedSettings() {
+	orphansMap := make(map[string]*OrphanedSettings)
+
+	addOrphans := func(tracks map[string]*AudioTrack, trackIDs map[string]int, basePath string) {
+		for key, value := range tracks {
+			if value.Hash == "" {
+				continue
+			}
+
+			if _, statError := os.Stat(filepath.Join(basePath, filepath.FromSlash(key))); statError == nil {
+				continue
+			}
+
+			orphansMap[value.Hash] = &OrphanedSettings{value, tracks, trackIDs, key}
+		}
+	}
+
+	addOrphans(g_appSettings.Tracks, g_appSettings.TrackIDs, "")
+
+	for _, item := range g_appSettings.Libraries {
+		addOrphans(item.Tracks, item.TrackIDs, item.FullPath())
+	}
+
+	if len(orphansMap) == 0 {
+		return
+	}
+
+	reattachedCount := 0
+
+	for _, item := range g_tracksList {
+		if item.Hash == "" {
+			continue
+		}
+
+		orphan, exists := orphansMap[item.Hash]
+
+		if !exists {
+			continue
+		}
+
+		tracks, trackIDs, settingsKey := settingsLocation(item.Root, item.Path)
+
+		if _, saved := tracks[settingsKey]; saved {
+			continue
+		}
+
+		delete(orphansMap, item.Hash)
+		delete(orphan.Tracks, orphan.Key)
+
+		item.Volume = orphan.Settings.Volume
+		item.Plays = orphan.Settings.Plays
+		item.TriggerMode = orphan.Settings.TriggerMode
+
+		if orphan.Settings.Binding != 0 && findBankTrack(orphan.Settings.Bank, orphan.Settings.Binding) == nil {
+			item.Binding = orphan.Settings.Binding
+			item.Bank = orphan.Settings.Bank
+
+			if item.ActiveBinding() != 0 {
+				g_keysMap[item.Binding] = item
+			}
+		}
+
+		if orphan.Settings.MIDIBinding != "" && g_midiMap[orphan.Settings.MIDIBinding] == nil {
+			item.MIDIBinding = orphan.Settings.MIDIBinding
+			g_midiMap[item.MIDIBinding] = item
+		}
+
+		if trackID, exists := orphan.TrackIDs[orphan.Key]; exists {
+			delete(orphan.TrackIDs, orphan.Key)
+
+			if getTrackByLabel(rootPrefixKey(item.Root)+strconv.Itoa(trackID)) == nil {
+				item.ID = trackID
+				trackIDs[settingsKey] = trackID
+			}
+		}
+
+		item.SaveSettings()
+
+		reattachedCount++
+	}
+
+	if reattachedCount == 0 {
+		return
+	}
+
+	g_filesTableModel.RowInserted(0)
+
+	logToEntry("Reattached the settings of %d moved tracks", reattachedCount)
+
+	go trySaveSettings()
+}
+
+func rescanLibraries() {
+	if dirError := fillTracksList(); dirError != nil {
+		logToEntry(dirError.Error())
+	}
+
+	g_filesTableModel.RowInserted(0)
+
+	logToEntry("Scanned %d tracks from %d library roots", len(g_tracksList), len(g_appSettings.Libraries))
+}
+
+func makeLibrariesTab() ui.Control {
+	vContainer := ui.NewVerticalBox()
+	vContainer.SetPadded(true)
+
+	buttonsBox := ui.NewHorizontalBox()
+	buttonsBox.SetPadded(true)
+
+	addButton := ui.NewButton("Add directory")
+	addButton.OnClicked(func(b *ui.Button) {
+		audioFolder := ui.OpenFolder(g_mainWindow)
+
+		if audioFolder == "" {
+			return
+		}
+
+		if addError := addLibraryRoot(audioFolder); addError != nil {
+			logToEntry(addError.Error())
+
+			return
+		}
+
+		g_librariesModel.RowInserted(0)
+
+		rescanLibraries()
+
+		go trySaveSettings()
+	})
+
+	buttonsBox.Append(addButton, false)
+
+	rescanButton := ui.NewButton("Rescan libraries")
+	rescanButton.OnClicked(func(b *ui.Button) {
+		rescanLibraries()
+	})
+
+	buttonsBox.Append(rescanButton, false)
+
+	g_librariesModel = ui.NewTableModel(&LibrariesTableModel{})
+	librariesTable := ui.NewTable(&ui.TableParams{
+		Model:                         g_librariesModel,
+		RowBackgroundColorModelColumn: 6,
+	})
+
+	librariesTable.AppendTextColumn("Directory", 0, ui.TableModelColumnNeverEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	librariesTable.AppendCheckboxColumn("Enabled", 1, ui.TableModelColumnAlwaysEditable)
+	librariesTable.AppendTextColumn("Default volume (%)", 2, ui.TableModelColumnAlwaysEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	librariesTable.AppendTextColumn("ID prefix", 3, ui.TableModelColumnAlwaysEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	librariesTable.AppendButtonColumn("Relocate", 5, ui.TableModelColumnAlwaysEditable)
+	librariesTable.AppendButtonColumn("Remove", 4, ui.TableModelColumnAlwaysEditable)
+
+	if len(g_appSettings.Libraries) != 0 {
+		g_librariesModel.RowInserted(0)
+	}
+
+	vContainer.Append(buttonsBox, false)
+	vContainer.Append(librariesTable, true)
+	vContainer.Append(ui.NewHorizontalSeparator(), false)
+	vContainer.Append(makePackBox(), false)
+	vContainer.Append(ui.NewLabel("Downloaded videos and imported packs are saved in the first enabled directory."), false)
+	vContainer.Append(ui.NewLabel("Relocate a directory after moving it to keep the volumes, bindings and IDs of its tracks."), false)
+	vContainer.Append(ui.NewLabel("Tracks of prefixed directories are played with the prefix before their ID (e.g. p12)."), false)
+
+	return vContainer
+}
+
+func (mh *LibrariesTableModel) ColumnTypes(m *ui.TableModel) []ui.TableValue {
+	return []ui.TableValue{
+		ui.TableString(""),
+		ui.TableInt(0),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableColor{},
+	}
+}
+
+func (mh *LibrariesTableModel) NumRows(m *ui.TableModel) int {
+	if len(g_appSettings.Libraries) == 0 {
+		return 0
+	}
+
+	return len(g_appSettings.Libraries) - 1
+}
+
+func (mh *LibrariesTableModel) CellValue(m *ui.TableModel, row, column int) ui.TableValue {
+	if column == 6 {
+		if row%2 == 0 {
+			return ui.TableColor{R: 0, G: 0, B: 0, A: 0.05}
+		}
+
+		return nil
+	}
+
+	if len(g_appSettings.Libraries) == 0 {
+		if column == 1 {
+			return ui.TableInt(0)
+		}
+
+		return ui.TableString("")
+	}
+
+	root := g_appSettings.Libraries[row]
+
+	switch column {
+	case 0:
+		return ui.TableString(root.Path)
+	case 1:
+		if root.Enabled {
+			return ui.TableInt(1)
+		}
+
+		return ui.TableInt(0)
+	case 2:
+		return ui.TableString(strconv.FormatFloat(float64(root.Volume), 'f', 2, 32))
+	case 3:
+		return ui.TableString(root.Prefix)
+	case 4:
+		return ui.TableString("Remove")
+	case 5:
+		return ui.TableString("Relocate")
+	}
+
+	return nil
+}
+
+func (mh *LibrariesTableModel) SetCellValue(m *ui.TableModel, row, column int, value ui.TableValue) {
+	if len(g_appSettings.Libraries) == 0 {
+		return
+	}
+
+	root := g_appSettings.Libraries[row]
+
+	if value == nil {
+		switch column {
+		case 4:
+			removeLibraryRoot(row)
+			m.RowInserted(0)
+
+			rescanLibraries()
+
+			go trySaveSettings()
+		case 5:
+			audioFolder := ui.OpenFolder(g_mainWindow)
+
+			if audioFolder == "" {
+				return
+			}
+
+			oldPath := root.Path
+
+			if relocateError := relocateLibraryRoot(root, audioFolder); relocateError != nil {
+				logToEntry(relocateError.Error())
+
+				return
+			}
+
+			logToEntry("Relocated library %s to %s", oldPath, root.Path)
+
+			m.RowChanged(row)
+
+			rescanLibraries()
+
+			go trySaveSettings()
+		}
+
+		return
+	}
+
+	switch column {
+	case 1:
+		root.Enabled = value.(ui.TableInt) == 1
+
+		rescanLibraries()
+	case 2:
+		newVolume, parseError := strconv.ParseFloat(string(value.(ui.TableString)), 32)
+
+		if parseError != nil {
+			logToEntry(parseError.Error())
+
+			return
+		}
+
+		if root.Volume == float32(newVolume) {
+			return
+		}
+
+		oldVolume := root.Volume
+		root.Volume = float32(newVolume)
+
+		// Tracks still at the old default follow the new one
+
+		for _, item := range g_tracksList {
+			if item.Root != root || item.Volume != oldVolume {
+				continue
+			}
+
+			item.Volume = root.Volume
+			item.SaveSettings()
+		}
+
+		g_filesTableModel.RowInserted(0)
+	case 3:
+		newPrefix := strings.TrimSpace(string(value.(ui.TableString)))
+
+		if newPrefix == root.Prefix {
+			return
+		}
+
+		if prefixError := validatePrefix(newPrefix); prefixError != nil {
+			logToEntry(prefixError.Error())
+
+			return
+		}
+
+		root.Prefix = newPrefix
+
+		rescanLibraries()
+	}
+
+	go trySaveSettings()
+}
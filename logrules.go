@@ -0,0 +1,540 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"waveboard/fixes/ui"
+)
+
+// LogRule runs an action on any console line matching its pattern, such as kill feed or connection lines.
+// The argument may use the groups captured by the pattern, as $1 or ${name}.
+
+type LogRule struct {
+	Pattern   string         `json:"pattern"`
+	Action    string         `json:"action"`
+	Argument  string         `json:"argument"`
+	Cooldown  float32        `json:"cooldown"`
+	Delay     float32        `json:"delay,omitempty"`
+	Enabled   bool           `json:"enabled"`
+	MatchChat bool           `json:"matchchat,omitempty"`
+	Regex     *regexp.Regexp `json:"-"`
+	LastRun   time.Time      `json:"-"`
+}
+
+type LogRulesTableModel struct{}
+
+var logRuleActionsList []string = []string{
+	"play",
+	"random",
+	"tts",
+}
+
+var logRuleActionNames map[string]string = map[string]string{
+	"play":   "Play track",
+	"random": "Play random track of a category",
+	"tts":    "Speak TTS",
+}
+
+// logRuleChatCommands are the chat commands matching the actions, which rules run for chat lines
+// so that users get the same permissions and queue limits as when typing the command.
+
+var logRuleChatCommands map[string]string = map[string]string{
+	"play":   "play",
+	"random": "random",
+	"tts":    "tts",
+}
+
+// g_logRulesMutex guards the rules and the scheduled tasks, run from background goroutines and edited from the interface.
+
+var g_logRulesMutex sync.Mutex
+var g_logRulesModel *ui.TableModel
+
+// g_chatLineRegex finds any chat message, while g_chatSeparatorRegex only finds commands.
+
+var g_chatLineRegex *regexp.Regexp = regexp.MustCompile(defaultChatSeparator)
+
+func (rule *LogRule) Compile() error {
+	compiledRegex, compileError := regexp.Compile(rule.Pattern)
+
+	if compileError != nil {
+		rule.Regex = nil
+
+		return compileError
+	}
+
+	rule.Regex = compiledRegex
+
+	return nil
+}
+
+// Expand returns the argument of the rule for the line, and false when the line does not match.
+
+func (rule *LogRule) Expand(line string) (string, bool) {
+	if rule.Regex == nil {
+		return "", false
+	}
+
+	match := rule.Regex.FindStringSubmatchIndex(line)
+
+	if match == nil {
+		return "", false
+	}
+
+	return strings.TrimSpace(string(rule.Regex.ExpandString(nil, rule.Argument, line, match))), true
+}
+
+func setupLogRules() {
+	g_logRulesMutex.Lock()
+	defer g_logRulesMutex.Unlock()
+
+	for _, item := range g_appSettings.LogRules {
+		if compileError := item.Compile(); compileError != nil {
+			logToEntry("Log rule \"%s\" is ignored : %s", item.Pattern, compileError.Error())
+		}
+	}
+}
+
+// runTrackAction plays or speaks the argument, queueing the tracks instead of playing them right away when asked.
+
+func runTrackAction(action string, argument string, queued bool) {
+	switch action {
+	case "play":
+		track, _ := findTrack(argument)
+
+		if track == nil {
+			ui.QueueMain(func() { logToEntry("No track found for \"%s\"", argument) })
+
+			return
+		}
+
+		playActionTrack(track, queued)
+	case "random":
+		tracks, filterError := getRandomCandidates(argument)
+
+		if filterError != nil {
+			ui.QueueMain(func() { logToEntry("Random filter \"%s\" : %s", argument, filterError.Error()) })
+
+			return
+		}
+
+		if track := g_randomPicker.Pick(tracks); track != nil {
+			playActionTrack(track, queued)
+		}
+	case "tts":
+		if argument != "" {
+			speakText(argument, g_selectedDevice)
+		}
+	}
+}
+
+func playActionTrack(track *AudioTrack, queued bool) {
+	if queued {
+		track.Queue("")
+
+		return
+	}
+
+	tryPlaySound(track, g_selectedDevice)
+}
+
+// findChatPlayer returns the user who sent the line, and false when it is not a chat message.
+
+func findChatPlayer(line string) (string, bool) {
+	separatorIndexes := g_chatLineRegex.FindStringIndex(line)
+
+	if separatorIndexes == nil {
+		return "", false
+	}
+
+	return trimChatPlayerName(line[:separatorIndexes[0]]), true
+}
+
+// matchLogRules runs every enabled rule matching the line, unless it ran within its cooldown.
+// Rules with a delay are handed to the scheduler, which stop all cancels.
+// Chat messages only match rules which opted in, and run the chat command of the action for their user,
+// so nobody can play tracks by typing what a rule looks for without being allowed to.
+
+func matchLogRules(line string) {
+	type ruleRun struct {
+		Action   string
+		Argument string
+		Delay    float32
+		Command  *LogCommand
+	}
+
+	var runs []ruleRun
+
+	playerName, isChat := findChatPlayer(line)
+
+	g_logRulesMutex.Lock()
+
+	for _, item := range g_appSettings.LogRules {
+		if !item.Enabled || (isChat && !item.MatchChat) {
+			continue
+		}
+
+		argument, matched := item.Expand(line)
+
+		if !matched || time.Since(item.LastRun) < time.Duration(item.Cooldown*float32(time.Second)) {
+			continue
+		}
+
+		var command *LogCommand
+
+		if isChat {
+			command = g_logCommands[logRuleChatCommands[item.Action]]
+
+			if command == nil || !canRunCommand(command, playerName) {
+				continue
+			}
+		}
+
+		item.LastRun = time.Now()
+
+		runs = append(runs, ruleRun{item.Action, argument, item.Delay, command})
+	}
+
+	g_logRulesMutex.Unlock()
+
+	for _, item := range runs {
+		run := item
+		runFunc := func() { runTrackAction(run.Action, run.Argument, false) }
+
+		if run.Command != nil {
+			if run.Argument == "" {
+				continue
+			}
+
+			runFunc = func() { run.Command.Action(playerName, run.Argument) }
+		}
+
+		if run.Delay > 0 {
+			g_scheduler.After(time.Duration(run.Delay*float32(time.Second)), runFunc)
+
+			continue
+		}
+
+		runFunc()
+	}
+}
+
+// testLogRules logs what every rule would do for the line, without running anything.
+
+func testLogRules(line string) {
+	g_logRulesMutex.Lock()
+	defer g_logRulesMutex.Unlock()
+
+	matches := 0
+
+	_, isChat := findChatPlayer(line)
+
+	for index, item := range g_appSettings.LogRules {
+		argument, matched := item.Expand(line)
+
+		if !matched {
+			continue
+		}
+
+		matches++
+
+		stateText := ""
+
+		if !item.Enabled {
+			stateText = " (disabled)"
+		} else if isChat && !item.MatchChat {
+			stateText = " (skips chat)"
+		}
+
+		logToEntry("Rule %d%s : %s \"%s\"", index+1, stateText, strings.ToLower(logRuleActionNames[item.Action]), argument)
+	}
+
+	if matches == 0 {
+		logToEntry("No rule matches \"%s\"", line)
+	}
+}
+
+func refreshLogRules() {
+	if g_logRulesModel != nil {
+		g_logRulesModel.RowInserted(0)
+	}
+}
+
+func makeLogRulesTab() ui.Control {
+	vContainer := ui.NewVerticalBox()
+	vContainer.SetPadded(true)
+
+	rulesForm := ui.NewForm()
+	rulesForm.SetPadded(true)
+
+	patternEntry := ui.NewEntry()
+
+	rulesForm.Append("Pattern :", patternEntry, false)
+
+	actionsComboBox := ui.NewCombobox()
+
+	for _, item := range logRuleActionsList {
+		actionsComboBox.Append(logRuleActionNames[item])
+	}
+
+	actionsComboBox.SetSelected(0)
+
+	rulesForm.Append("Action :", actionsComboBox, false)
+
+	argumentEntry := ui.NewEntry()
+
+	rulesForm.Append("Track, category or text :", argumentEntry, false)
+
+	delayEntry := ui.NewEntry()
+	delayEntry.SetText("0")
+
+	rulesForm.Append("Delay (s) :", delayEntry, false)
+
+	chatCheckbox := ui.NewCheckbox("Match chat messages")
+
+	rulesForm.Append("", chatCheckbox, false)
+
+	addGrid := ui.NewGrid()
+	addGrid.SetPadded(true)
+
+	cooldownEntry := ui.NewEntry()
+	cooldownEntry.SetText("0")
+
+	addGrid.Append(cooldownEntry, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	addButton := ui.NewButton("Add rule")
+	addButton.OnClicked(func(b *ui.Button) {
+		cooldown, convError := strconv.ParseFloat(cooldownEntry.Text(), 32)
+
+		if convError != nil || cooldown < 0 {
+			logToEntry("Cooldown must be a positive number of seconds")
+
+			return
+		}
+
+		delay, convError := strconv.ParseFloat(delayEntry.Text(), 32)
+
+		if convError != nil || delay < 0 {
+			logToEntry("Delay must be a positive number of seconds")
+
+			return
+		}
+
+		rule := &LogRule{
+			Pattern:   patternEntry.Text(),
+			Action:    logRuleActionsList[actionsComboBox.Selected()],
+			Argument:  strings.TrimSpace(argumentEntry.Text()),
+			Cooldown:  float32(cooldown),
+			Delay:     float32(delay),
+			Enabled:   true,
+			MatchChat: chatCheckbox.Checked(),
+		}
+
+		if rule.Pattern == "" {
+			return
+		}
+
+		if compileError := rule.Compile(); compileError != nil {
+			logToEntry(compileError.Error())
+
+			return
+		}
+
+		g_logRulesMutex.Lock()
+		g_appSettings.LogRules = append(g_appSettings.LogRules, rule)
+		g_logRulesMutex.Unlock()
+
+		patternEntry.SetText("")
+		argumentEntry.SetText("")
+
+		refreshLogRules()
+
+		go trySaveSettings()
+	})
+
+	addGrid.Append(addButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	rulesForm.Append("Cooldown (s) :", addGrid, false)
+
+	testGrid := ui.NewGrid()
+	testGrid.SetPadded(true)
+
+	testEntry := ui.NewEntry()
+
+	testGrid.Append(testEntry, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	testButton := ui.NewButton("Test line")
+	testButton.OnClicked(func(b *ui.Button) {
+		testLogRules(testEntry.Text())
+	})
+
+	testGrid.Append(testButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	rulesForm.Append("Sample console line :", testGrid, false)
+
+	g_logRulesModel = ui.NewTableModel(&LogRulesTableModel{})
+	rulesTable := ui.NewTable(&ui.TableParams{
+		Model:                         g_logRulesModel,
+		RowBackgroundColorModelColumn: 8,
+	})
+
+	rulesTable.AppendCheckboxColumn("Enabled", 0, ui.TableModelColumnAlwaysEditable)
+	rulesTable.AppendTextColumn("Pattern", 1, ui.TableModelColumnAlwaysEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	rulesTable.AppendTextColumn("Action", 2, ui.TableModelColumnNeverEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	rulesTable.AppendTextColumn("Argument", 3, ui.TableModelColumnAlwaysEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	rulesTable.AppendTextColumn("Cooldown (s)", 4, ui.TableModelColumnAlwaysEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	rulesTable.AppendTextColumn("Delay (s)", 5, ui.TableModelColumnAlwaysEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	rulesTable.AppendCheckboxColumn("Chat", 7, ui.TableModelColumnAlwaysEditable)
+	rulesTable.AppendButtonColumn("Remove", 6, ui.TableModelColumnAlwaysEditable)
+
+	refreshLogRules()
+
+	vContainer.Append(rulesForm, false)
+	vContainer.Append(rulesTable, true)
+	vContainer.Append(ui.NewLabel("Rules are checked against every line of the watched log, groups captured by the pattern can be used as $1 or ${name}."), false)
+	vContainer.Append(ui.NewLabel("Chat messages are skipped unless the rule matches chat, and then run the command of the action with the permissions of the user."), false)
+	vContainer.Append(ui.NewLabel("Sample lines are only logged with the actions they would run."), false)
+
+	return vContainer
+}
+
+func (mh *LogRulesTableModel) ColumnTypes(m *ui.TableModel) []ui.TableValue {
+	return []ui.TableValue{
+		ui.TableInt(0),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableInt(0),
+		ui.TableColor{},
+	}
+}
+
+func (mh *LogRulesTableModel) NumRows(m *ui.TableModel) int {
+	if len(g_appSettings.LogRules) == 0 {
+		return 0
+	}
+
+	return len(g_appSettings.LogRules) - 1
+}
+
+func (mh *LogRulesTableModel) CellValue(m *ui.TableModel, row, column int) ui.TableValue {
+	if column == 8 {
+		if row%2 == 0 {
+			return ui.TableColor{R: 0, G: 0, B: 0, A: 0.05}
+		}
+
+		return nil
+	}
+
+	if row >= len(g_appSettings.LogRules) {
+		if column == 0 || column == 7 {
+			return ui.TableInt(0)
+		}
+
+		return ui.TableString("")
+	}
+
+	rule := g_appSettings.LogRules[row]
+
+	switch column {
+	case 0:
+		if rule.Enabled {
+			return ui.TableInt(1)
+		}
+
+		return ui.TableInt(0)
+	case 1:
+		return ui.TableString(rule.Pattern)
+	case 2:
+		return ui.TableString(logRuleActionNames[rule.Action])
+	case 3:
+		return ui.TableString(rule.Argument)
+	case 4:
+		return ui.TableString(strconv.FormatFloat(float64(rule.Cooldown), 'f', -1, 32))
+	case 5:
+		return ui.TableString(strconv.FormatFloat(float64(rule.Delay), 'f', -1, 32))
+	case 6:
+		return ui.TableString("Remove")
+	case 7:
+		if rule.MatchChat {
+			return ui.TableInt(1)
+		}
+
+		return ui.TableInt(0)
+	}
+
+	return nil
+}
+
+func (mh *LogRulesTableModel) SetCellValue(m *ui.TableModel, row, column int, value ui.TableValue) {
+	if row >= len(g_appSettings.LogRules) {
+		return
+	}
+
+	g_logRulesMutex.Lock()
+	defer g_logRulesMutex.Unlock()
+
+	rule := g_appSettings.LogRules[row]
+
+	if value == nil {
+		switch column {
+		case 6:
+			g_appSettings.LogRules = append(g_appSettings.LogRules[:row], g_appSettings.LogRules[row+1:]...)
+
+			m.RowInserted(0)
+
+			go trySaveSettings()
+		}
+
+		return
+	}
+
+	switch column {
+	case 0:
+		rule.Enabled = value.(ui.TableInt) == 1
+	case 1:
+		previousPattern := rule.Pattern
+		rule.Pattern = string(value.(ui.TableString))
+
+		if compileError := rule.Compile(); compileError != nil {
+			logToEntry(compileError.Error())
+
+			rule.Pattern = previousPattern
+			rule.Compile()
+
+			return
+		}
+	case 3:
+		rule.Argument = strings.TrimSpace(string(value.(ui.TableString)))
+	case 4:
+		cooldown, convError := strconv.ParseFloat(string(value.(ui.TableString)), 32)
+
+		if convError != nil || cooldown < 0 {
+			logToEntry("Cooldown must be a positive number of seconds")
+
+			return
+		}
+
+		rule.Cooldown = float32(cooldown)
+	case 5:
+		delay, convError := strconv.ParseFloat(string(value.(ui.TableString)), 32)
+
+		if convError != nil || delay < 0 {
+			logToEntry("Delay must be a positive number of seconds")
+
+			return
+		}
+
+		rule.Delay = float32(delay)
+	case 7:
+		rule.MatchChat = value.(ui.TableInt) == 1
+	}
+
+	go trySaveSettings()
+}
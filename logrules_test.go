@@ -0,0 +1,208 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestLogRuleExpand(t *testing.T) {
+	killPattern := `^(?P<killer>.+) killed (?P<victim>.+) with (\w+)\.( \(crit\))?$`
+
+	testCases := []struct {
+		Pattern  string
+		Argument string
+		Line     string
+		Result   string
+		Matched  bool
+	}{
+		{killPattern, "${killer} fragged ${victim}", "Scout killed Heavy Weapons Guy with scattergun.", "Scout fragged Heavy Weapons Guy", true},
+		{killPattern, "$3", "Sniper killed Spy with sniperrifle. (crit)", "sniperrifle", true},
+		{killPattern, "headshot $4", "Sniper killed Spy with sniperrifle.", "headshot", true},
+		{killPattern, "$killer", "Scout :  Heavy killed me with minigun", "", false},
+		{`^(.+) connected$`, "welcome $1", "xX_Soldier_Xx connected", "welcome xX_Soldier_Xx", true},
+		{`^(.+) connected$`, "welcome $1", "xX_Soldier_Xx disconnected (Disconnect by user.)", "", false},
+		{`World triggered "Round_Start"`, "round start", `L 10/18/2026 - 20:15:03: World triggered "Round_Start"`, "round start", true},
+		{`World triggered "Round_Start"`, "round start", `L 10/18/2026 - 20:17:41: World triggered "Round_End"`, "", false},
+		{`(?i)round start`, "  $0  ", "ROUND START", "ROUND START", true},
+	}
+
+	for _, testCase := range testCases {
+		rule := &LogRule{Pattern: testCase.Pattern, Argument: testCase.Argument}
+
+		if compileError := rule.Compile(); compileError != nil {
+			t.Fatalf("Compile(%q) : %v", testCase.Pattern, compileError)
+		}
+
+		result, matched := rule.Expand(testCase.Line)
+
+		if result != testCase.Result || matched != testCase.Matched {
+			t.Errorf("Expand(%q) with %q returned %q, %v, want %q, %v", testCase.Line, testCase.Argument, result, matched, testCase.Result, testCase.Matched)
+		}
+	}
+
+	rule := &LogRule{Pattern: "(unclosed", Argument: "x"}
+
+	if compileError := rule.Compile(); compileError == nil || rule.Regex != nil {
+		t.Fatal("Compile accepted an invalid pattern")
+	}
+
+	if _, matched := rule.Expand("(unclosed"); matched {
+		t.Fatal("A rule with an invalid pattern matched")
+	}
+}
+
+// useLogRules replaces the rules with a single one, whose empty TTS action does nothing when it runs.
+// The TTS chat command is replaced too, and the returned list records what it ran.
+
+func useLogRules(t *testing.T, pattern string, cooldown float32) (*LogRule, *[]string) {
+	previousSettings, previousPrefix, previousCommands := g_appSettings, g_chatPrefixRegex, g_logCommands
+
+	t.Cleanup(func() {
+		g_appSettings, g_chatPrefixRegex, g_logCommands = previousSettings, previousPrefix, previousCommands
+	})
+
+	var commandRuns []string
+
+	g_logCommands = map[string]*LogCommand{
+		"tts": {Action: func(playerName string, arg string) { commandRuns = append(commandRuns, playerName+" "+arg) }},
+	}
+
+	rule := &LogRule{Pattern: pattern, Action: "tts", Cooldown: cooldown, Enabled: true}
+
+	if compileError := rule.Compile(); compileError != nil {
+		t.Fatal(compileError)
+	}
+
+	g_appSettings.LogRules = []*LogRule{rule}
+	g_appSettings.Timestamped = false
+	g_appSettings.BlockedUsers = nil
+	g_appSettings.AllowedUsers = nil
+	g_chatPrefixRegex = regexp.MustCompile(defaultChatPrefix)
+
+	return rule, &commandRuns
+}
+
+func TestMatchLogRulesCooldown(t *testing.T) {
+	rule, _ := useLogRules(t, `^(.+) connected$`, 60)
+
+	matchLogRules("xX_Soldier_Xx connected")
+
+	firstRun := rule.LastRun
+
+	if firstRun.IsZero() {
+		t.Fatal("The rule did not run on a matching line")
+	}
+
+	matchLogRules("Medic connected")
+
+	if !rule.LastRun.Equal(firstRun) {
+		t.Fatal("The rule ran again within its cooldown")
+	}
+
+	rule.LastRun = time.Now().Add(-61 * time.Second)
+
+	if matchLogRules("Medic connected"); time.Since(rule.LastRun) > time.Second {
+		t.Fatal("The rule did not run once its cooldown was over")
+	}
+
+	rule.LastRun = time.Time{}
+	rule.Enabled = false
+
+	if matchLogRules("Medic connected"); !rule.LastRun.IsZero() {
+		t.Fatal("A disabled rule ran")
+	}
+}
+
+func TestMatchLogRulesBlockedChat(t *testing.T) {
+	rule, _ := useLogRules(t, `\bgg\b`, 0)
+	rule.MatchChat = true
+
+	testCases := []struct {
+		Line    string
+		Allowed []string
+		Blocked []string
+		Runs    bool
+	}{
+		{"Troll :  gg", nil, []string{"Troll"}, false},
+		{"*DEAD* Troll :  gg", nil, []string{"Troll"}, false},
+		{"(TEAM) Troll : gg", nil, []string{"Troll"}, false},
+		{"Friend :  gg", nil, []string{"Troll"}, true},
+		{"Troll :  gg", []string{"Troll"}, []string{"Troll"}, true},
+		{"Troll :  gg", nil, []string{"*"}, false},
+		{"Friend :  gg", []string{"Friend"}, []string{"*"}, true},
+		{"Troll killed Friend with gg.", nil, []string{"*"}, true},
+	}
+
+	for _, testCase := range testCases {
+		g_appSettings.AllowedUsers = testCase.Allowed
+		g_appSettings.BlockedUsers = testCase.Blocked
+		rule.LastRun = time.Time{}
+
+		if matchLogRules(testCase.Line); rule.LastRun.IsZero() == testCase.Runs {
+			t.Errorf("Rule ran on %q with allowed %q and blocked %q : %v, want %v", testCase.Line, testCase.Allowed, testCase.Blocked, !testCase.Runs, testCase.Runs)
+		}
+	}
+
+	g_appSettings.Timestamped = true
+	g_appSettings.AllowedUsers = nil
+	g_appSettings.BlockedUsers = []string{"Troll"}
+	rule.LastRun = time.Time{}
+
+	if matchLogRules("10/18/2026 - 20:15:03: *DEAD* Troll :  gg"); !rule.LastRun.IsZero() {
+		t.Fatal("Rule ran on a timestamped chat line of a blocked user")
+	}
+}
+
+func TestMatchLogRulesChat(t *testing.T) {
+	rule, commandRuns := useLogRules(t, `\bgg\b`, 0)
+	rule.Argument = "good game"
+
+	// Chat messages are skipped unless the rule opts in
+
+	if matchLogRules("Friend :  gg"); !rule.LastRun.IsZero() || len(*commandRuns) != 0 {
+		t.Fatal("A rule which does not match chat ran on a chat line")
+	}
+
+	if matchLogRules("Friend killed Troll with gg."); rule.LastRun.IsZero() || len(*commandRuns) != 0 {
+		t.Fatal("A console line did not run the rule action")
+	}
+
+	rule.MatchChat = true
+
+	testCases := []struct {
+		Line        string
+		AllowedOnly bool
+		Allowed     []string
+		Runs        []string
+	}{
+		{"Friend :  gg", false, nil, []string{"Friend good game"}},
+		{"*DEAD* Friend :  gg", false, nil, []string{"Friend good game"}},
+		{"Friend :  gg", true, nil, nil},
+		{"Friend :  gg", true, []string{"Friend"}, []string{"Friend good game"}},
+		{"Troll :  gg", false, nil, nil},
+		{"Troll :  gg", false, []string{"Troll"}, []string{"Troll good game"}},
+	}
+
+	for _, testCase := range testCases {
+		g_logCommands["tts"].AllowedOnly = testCase.AllowedOnly
+		g_appSettings.AllowedUsers = testCase.Allowed
+		g_appSettings.BlockedUsers = []string{"Troll"}
+		rule.LastRun = time.Time{}
+		*commandRuns = nil
+
+		if matchLogRules(testCase.Line); !reflect.DeepEqual(*commandRuns, testCase.Runs) || rule.LastRun.IsZero() == (testCase.Runs != nil) {
+			t.Errorf("Rule on %q with allowed-only %v and allowed %q ran %q, want %q", testCase.Line, testCase.AllowedOnly, testCase.Allowed, *commandRuns, testCase.Runs)
+		}
+	}
+
+	// Chat rules of actions without a chat command never run
+
+	rule.Action = "unknown"
+	rule.LastRun = time.Time{}
+
+	if matchLogRules("Friend :  gg"); !rule.LastRun.IsZero() {
+		t.Fatal("A chat line ran a rule without a chat command")
+	}
+}
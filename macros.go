@@ -0,0 +1,528 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"waveboard/fixes/ui"
+
+	"github.com/moutend/go-hook/pkg/types"
+)
+
+// Macro runs its steps one after another, from a hotkey or the chat.
+// Queued macros add their tracks to the queue, the others play them right away and wait for them to end.
+
+type MacroStep struct {
+	Action   string `json:"action"`
+	Argument string `json:"argument,omitempty"`
+}
+
+type Macro struct {
+	Name    string       `json:"name"`
+	Steps   []*MacroStep `json:"steps"`
+	Queued  bool         `json:"queued"`
+	Binding types.VKCode `json:"binding,omitempty"`
+}
+
+type MacrosTableModel struct {
+	editMacro func(*Macro)
+}
+
+const macroPollInterval = 50 * time.Millisecond
+
+var macroActionsList []string = []string{
+	"play",
+	"tts",
+	"wait",
+	"volume",
+	"skip",
+}
+
+// g_macrosMutex guards the macros list, read by the keyboard hook and the chat.
+// g_macroCancel is closed by stop all, ending every running macro.
+
+var g_macrosMutex sync.Mutex
+var g_macroCancel chan struct{} = make(chan struct{})
+var g_bindingMacro *Macro
+var g_macrosModel *ui.TableModel
+
+func parseMacroSteps(text string) ([]*MacroStep, error) {
+	var steps []*MacroStep
+
+	for index, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		}
+
+		action, argument, _ := strings.Cut(line, " ")
+		action = strings.ToLower(action)
+		argument = strings.TrimSpace(argument)
+
+		switch action {
+		case "play", "tts":
+			if argument == "" {
+				return nil, fmt.Errorf("Line %d : %s needs an argument", index+1, action)
+			}
+		case "wait":
+			if waitTime, convError := strconv.ParseUint(argument, 10, 32); convError != nil || waitTime == 0 {
+				return nil, fmt.Errorf("Line %d : wait needs a positive number of milliseconds", index+1)
+			}
+		case "volume":
+			if volume, convError := strconv.ParseFloat(argument, 32); convError != nil || volume < 0 {
+				return nil, fmt.Errorf("Line %d : volume needs a positive percentage", index+1)
+			}
+		case "skip":
+			argument = ""
+		default:
+			return nil, fmt.Errorf("Line %d : unknown step \"%s\", use %s", index+1, action, strings.Join(macroActionsList, ", "))
+		}
+
+		steps = append(steps, &MacroStep{action, argument})
+	}
+
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("A macro needs at least one step")
+	}
+
+	return steps, nil
+}
+
+func formatMacroSteps(steps []*MacroStep, separator string) string {
+	lines := make([]string, 0, len(steps))
+
+	for _, item := range steps {
+		lines = append(lines, strings.TrimSpace(item.Action+" "+item.Argument))
+	}
+
+	return strings.Join(lines, separator)
+}
+
+func findMacro(name string) *Macro {
+	g_macrosMutex.Lock()
+	defer g_macrosMutex.Unlock()
+
+	for _, item := range g_appSettings.Macros {
+		if strings.EqualFold(item.Name, name) {
+			return item
+		}
+	}
+
+	return nil
+}
+
+func findKeyMacro(key types.VKCode) *Macro {
+	g_macrosMutex.Lock()
+	defer g_macrosMutex.Unlock()
+
+	for _, item := range g_appSettings.Macros {
+		if item.Binding == key {
+			return item
+		}
+	}
+
+	return nil
+}
+
+func cancelMacros() {
+	g_macrosMutex.Lock()
+	defer g_macrosMutex.Unlock()
+
+	close(g_macroCancel)
+	g_macroCancel = make(chan struct{})
+}
+
+// waitMacro returns false when the macros were cancelled during the wait.
+
+func waitMacro(duration time.Duration, cancel chan struct{}) bool {
+	select {
+	case <-cancel:
+		return false
+	case <-time.After(duration):
+		return true
+	}
+}
+
+func waitTrackEnd(track *AudioTrack, cancel chan struct{}) bool {
+	for isCurrentTrack(track) {
+		if !waitMacro(macroPollInterval, cancel) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (macro *Macro) Run(requester string) {
+	g_macrosMutex.Lock()
+	steps := macro.Steps
+	queued := macro.Queued
+	cancel := g_macroCancel
+	g_macrosMutex.Unlock()
+
+	for _, item := range steps {
+		select {
+		case <-cancel:
+			return
+		default:
+		}
+
+		switch item.Action {
+		case "play":
+			track, _ := findTrack(item.Argument)
+
+			if track == nil {
+				argument := item.Argument
+
+				ui.QueueMain(func() { logToEntry("Macro %s found no track for \"%s\"", macro.Name, argument) })
+
+				continue
+			}
+
+			if queued {
+				if g_audioQueue.IsFull(g_appSettings.QueueLimit) {
+					stopQueuedMacro(macro, requester, "macro.queuefull", g_appSettings.QueueLimit)
+
+					return
+				}
+
+				if g_audioQueue.HasReachedQuota(requester, g_appSettings.UserQueueLimit) {
+					stopQueuedMacro(macro, requester, "macro.quota", g_appSettings.UserQueueLimit)
+
+					return
+				}
+
+				track.Queue(requester)
+
+				continue
+			}
+
+			tryPlaySoundFrom(track, g_selectedDevice, 0, requester)
+
+			if !waitTrackEnd(track, cancel) {
+				return
+			}
+		case "tts":
+			speakText(item.Argument, g_selectedDevice)
+		case "wait":
+			waitTime, _ := strconv.ParseUint(item.Argument, 10, 32)
+
+			if !waitMacro(time.Duration(waitTime)*time.Millisecond, cancel) {
+				return
+			}
+		case "volume":
+			volume, _ := strconv.ParseFloat(item.Argument, 32)
+
+			setGlobalVolume(float32(volume))
+		case "skip":
+			skipCurrentTrack()
+		}
+	}
+}
+
+// stopQueuedMacro tells why a queued macro stopped, in the chat when a user ran it.
+// Macros run from the interface have no quota, so only a full queue stops them.
+
+func stopQueuedMacro(macro *Macro, requester string, replyName string, limit int) {
+	if requester == "" {
+		ui.QueueMain(func() { logToEntry("Macro %s stopped, the queue is full (%d entries)", macro.Name, limit) })
+
+		return
+	}
+
+	sendReply(replyName, "{user}", requester, "{name}", macro.Name, "{limit}", strconv.Itoa(limit))
+}
+
+func unbindMacroKey(key types.VKCode) {
+	macro := findKeyMacro(key)
+
+	if macro == nil {
+		return
+	}
+
+	g_macrosMutex.Lock()
+	macro.Binding = 0
+	g_macrosMutex.Unlock()
+
+	ui.QueueMain(func() {
+		logToEntry("Unbound %s from the %s macro", formatKey(key), macro.Name)
+		refreshMacros()
+	})
+}
+
+// bindMacroKey takes the key away from any action, macro or track which used it, in every bank.
+
+func bindMacroKey(key types.VKCode) {
+	macro := g_bindingMacro
+	g_bindingMacro = nil
+
+	if key == deleteKey {
+		key = 0
+	} else {
+		clearKeyBindings(key)
+		unbindTransportKey(key)
+		unbindMacroKey(key)
+	}
+
+	g_macrosMutex.Lock()
+	macro.Binding = key
+	g_macrosMutex.Unlock()
+
+	ui.QueueMain(refreshMacros)
+
+	go trySaveSettings()
+}
+
+func cancelMacroBinding() {
+	if g_bindingMacro == nil {
+		return
+	}
+
+	g_bindingMacro = nil
+
+	refreshMacros()
+}
+
+func refreshMacros() {
+	if g_macrosModel != nil {
+		g_macrosModel.RowInserted(0)
+	}
+}
+
+func macroCommand(playerName string, arg string) {
+	if arg == "" {
+		return
+	}
+
+	macro := findMacro(arg)
+
+	if macro == nil {
+		sendReply("macro.notfound", "{user}", playerName, "{arg}", arg)
+
+		return
+	}
+
+	sendReply("macro.running", "{user}", playerName, "{name}", macro.Name)
+
+	go macro.Run(playerName)
+}
+
+func makeMacrosTab() ui.Control {
+	vContainer := ui.NewVerticalBox()
+	vContainer.SetPadded(true)
+
+	macroForm := ui.NewForm()
+	macroForm.SetPadded(true)
+
+	nameEntry := ui.NewEntry()
+
+	macroForm.Append("Name :", nameEntry, false)
+
+	stepsEntry := ui.NewNonWrappingMultilineEntry()
+
+	macroForm.Append("Steps :", stepsEntry, true)
+
+	saveGrid := ui.NewGrid()
+	saveGrid.SetPadded(true)
+
+	queuedCheckbox := ui.NewCheckbox("Add tracks to the queue")
+
+	saveGrid.Append(queuedCheckbox, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	saveButton := ui.NewButton("Save macro")
+	saveButton.OnClicked(func(b *ui.Button) {
+		name := strings.TrimSpace(nameEntry.Text())
+
+		if name == "" || strings.ContainsAny(name, " \t") {
+			logToEntry("Macro names cannot be empty or contain spaces")
+
+			return
+		}
+
+		steps, parseError := parseMacroSteps(stepsEntry.Text())
+
+		if parseError != nil {
+			logToEntry(parseError.Error())
+
+			return
+		}
+
+		if macro := findMacro(name); macro != nil {
+			g_macrosMutex.Lock()
+			macro.Steps = steps
+			macro.Queued = queuedCheckbox.Checked()
+			g_macrosMutex.Unlock()
+
+			logToEntry("Updated the %s macro", macro.Name)
+		} else {
+			g_macrosMutex.Lock()
+			g_appSettings.Macros = append(g_appSettings.Macros, &Macro{Name: name, Steps: steps, Queued: queuedCheckbox.Checked()})
+			g_macrosMutex.Unlock()
+		}
+
+		nameEntry.SetText("")
+		stepsEntry.SetText("")
+
+		refreshMacros()
+
+		go trySaveSettings()
+	})
+
+	saveGrid.Append(saveButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	macroForm.Append("", saveGrid, false)
+
+	g_macrosModel = ui.NewTableModel(&MacrosTableModel{editMacro: func(macro *Macro) {
+		nameEntry.SetText(macro.Name)
+		stepsEntry.SetText(formatMacroSteps(macro.Steps, "\n"))
+		queuedCheckbox.SetChecked(macro.Queued)
+	}})
+	macrosTable := ui.NewTable(&ui.TableParams{
+		Model:                         g_macrosModel,
+		RowBackgroundColorModelColumn: 7,
+	})
+
+	macrosTable.AppendTextColumn("Name", 0, ui.TableModelColumnNeverEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	macrosTable.AppendTextColumn("Steps", 1, ui.TableModelColumnNeverEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	macrosTable.AppendCheckboxColumn("Queued", 2, ui.TableModelColumnAlwaysEditable)
+	macrosTable.AppendButtonColumn("Binding", 3, ui.TableModelColumnAlwaysEditable)
+	macrosTable.AppendButtonColumn("Run", 4, ui.TableModelColumnAlwaysEditable)
+	macrosTable.AppendButtonColumn("Edit", 5, ui.TableModelColumnAlwaysEditable)
+	macrosTable.AppendButtonColumn("Remove", 6, ui.TableModelColumnAlwaysEditable)
+
+	refreshMacros()
+
+	vContainer.Append(macroForm, false)
+	vContainer.Append(macrosTable, true)
+	vContainer.Append(ui.NewLabel("One step per line : play <track>, tts <text>, wait <milliseconds>, volume <global volume> or skip."), false)
+	vContainer.Append(ui.NewLabel("Macros also run from chat with the macro command, stop all cancels every running macro."), false)
+
+	return vContainer
+}
+
+func (mh *MacrosTableModel) ColumnTypes(m *ui.TableModel) []ui.TableValue {
+	return []ui.TableValue{
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableInt(0),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableColor{},
+	}
+}
+
+func (mh *MacrosTableModel) NumRows(m *ui.TableModel) int {
+	if len(g_appSettings.Macros) == 0 {
+		return 0
+	}
+
+	return len(g_appSettings.Macros) - 1
+}
+
+func (mh *MacrosTableModel) CellValue(m *ui.TableModel, row, column int) ui.TableValue {
+	if column == 7 {
+		if row%2 == 0 {
+			return ui.TableColor{R: 0, G: 0, B: 0, A: 0.05}
+		}
+
+		return nil
+	}
+
+	if row >= len(g_appSettings.Macros) {
+		if column == 2 {
+			return ui.TableInt(0)
+		}
+
+		return ui.TableString("")
+	}
+
+	macro := g_appSettings.Macros[row]
+
+	switch column {
+	case 0:
+		return ui.TableString(macro.Name)
+	case 1:
+		return ui.TableString(formatMacroSteps(macro.Steps, ", "))
+	case 2:
+		if macro.Queued {
+			return ui.TableInt(1)
+		}
+
+		return ui.TableInt(0)
+	case 3:
+		if g_bindingMacro == macro {
+			return ui.TableString("Binding to ...")
+		}
+
+		if macro.Binding != 0 {
+			return ui.TableString(formatKey(macro.Binding))
+		}
+
+		return ui.TableString("Bind to key")
+	case 4:
+		return ui.TableString("Run")
+	case 5:
+		return ui.TableString("Edit")
+	case 6:
+		return ui.TableString("Remove")
+	}
+
+	return nil
+}
+
+func (mh *MacrosTableModel) SetCellValue(m *ui.TableModel, row, column int, value ui.TableValue) {
+	if row >= len(g_appSettings.Macros) {
+		return
+	}
+
+	macro := g_appSettings.Macros[row]
+
+	if value == nil {
+		switch column {
+		case 3:
+			if g_bindingRow != -1 {
+				g_filesTableModel.RowChanged(g_bindingRow)
+				g_bindingRow = -1
+			}
+
+			cancelTransportBinding()
+
+			g_bindingMacro = macro
+
+			m.RowInserted(0)
+		case 4:
+			go macro.Run("")
+		case 5:
+			mh.editMacro(macro)
+		case 6:
+			if g_bindingMacro == macro {
+				g_bindingMacro = nil
+			}
+
+			g_macrosMutex.Lock()
+			g_appSettings.Macros = append(g_appSettings.Macros[:row], g_appSettings.Macros[row+1:]...)
+			g_macrosMutex.Unlock()
+
+			m.RowInserted(0)
+
+			go trySaveSettings()
+		}
+
+		return
+	}
+
+	switch column {
+	case 2:
+		g_macrosMutex.Lock()
+		macro.Queued = value.(ui.TableInt) == 1
+		g_macrosMutex.Unlock()
+	}
+
+	go trySaveSettings()
+}
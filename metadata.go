@@ -0,0 +1,317 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"waveboard/fixes/gosndfile/sndfile"
+	"waveboard/fixes/ui"
+
+	"github.com/bep/debounce"
+)
+
+type TrackMetadata struct {
+	Size       int64         `json:"size"`
+	ModTime    int64         `json:"mtime"`
+	Duration   time.Duration `json:"duration"`
+	SampleRate int32         `json:"samplerate"`
+	Channels   int32         `json:"channels"`
+	Title      string        `json:"title"`
+	Artist     string        `json:"artist"`
+	Comment    string        `json:"comment"`
+	Hash       string        `json:"hash"`
+}
+
+const metadataFileName = "waveboard.metadata.json"
+const metadataBatchSize = 100
+
+var g_metadataCache map[string]*TrackMetadata = make(map[string]*TrackMetadata)
+var g_metadataMutex sync.Mutex
+var g_metadataGeneration atomic.Int64
+var g_metadataSaveFunc = debounce.New(2 * time.Second)
+
+func setupMetadata() {
+	currentPath, wdError := os.Getwd()
+
+	if wdError != nil {
+		logToEntry(wdError.Error())
+
+		return
+	}
+
+	metadataContents, readError := os.ReadFile(filepath.Join(currentPath, metadataFileName))
+
+	if readError != nil {
+		if !errors.Is(readError, fs.ErrNotExist) {
+			logToEntry(readError.Error())
+		}
+
+		return
+	}
+
+	if jsonError := json.Unmarshal(metadataContents, &g_metadataCache); jsonError != nil {
+		logToEntry("Could not read %s : %s", metadataFileName, jsonError.Error())
+
+		g_metadataCache = make(map[string]*TrackMetadata)
+
+		return
+	}
+
+	metadataContents = nil
+
+	logToEntry("Loaded cached metadata of %d tracks", len(g_metadataCache))
+}
+
+func saveMetadata() error {
+	currentPath, wdError := os.Getwd()
+
+	if wdError != nil {
+		return wdError
+	}
+
+	g_metadataMutex.Lock()
+	jsonMetadata, marshalError := json.Marshal(g_metadataCache)
+	g_metadataMutex.Unlock()
+
+	if marshalError != nil {
+		return marshalError
+	}
+
+	return os.WriteFile(filepath.Join(currentPath, metadataFileName), jsonMetadata, 0644)
+}
+
+func trySaveMetadata() {
+	g_metadataSaveFunc(func() {
+		if saveError := saveMetadata(); saveError != nil {
+			ui.QueueMain(func() { logToEntry(saveError.Error()) })
+		}
+	})
+}
+
+// startMetadataIndexer stops any previous scan, as its tracks were replaced.
+
+func startMetadataIndexer(tracks []*AudioTrack) {
+	generation := g_metadataGeneration.Add(1)
+
+	go indexMetadata(append([]*AudioTrack(nil), tracks...), generation, true)
+}
+
+// applyMetadata hands metadata read in the background to the UI thread, which owns the tracks and the table.
+
+func applyMetadata(tracks []*AudioTrack, metadataList []*TrackMetadata) {
+	ui.QueueMain(func() {
+		for index, item := range tracks {
+			item.ApplyMetadata(metadataList[index])
+		}
+
+		g_filesTableModel.RowInserted(0)
+	})
+}
+
+func indexMetadata(tracks []*AudioTrack, generation int64, fullScan bool) {
+	var newCount int
+	var visitedPaths map[string]bool
+	var batchTracks []*AudioTrack
+	var batchMetadata []*TrackMetadata
+
+	if fullScan {
+		visitedPaths = make(map[string]bool, len(tracks))
+	}
+
+	for index, item := range tracks {
+		if g_metadataGeneration.Load() != generation {
+			return
+		}
+
+		if fullScan {
+			visitedPaths[filepath.ToSlash(item.Path)] = true
+		}
+
+		metadata, cached, metadataError := readTrackMetadata(item.Path)
+
+		if metadataError != nil {
+			ui.QueueMain(func() {
+				logToEntry("Could not read metadata of %s%s : %s", item.Name, item.Extension, metadataError.Error())
+			})
+
+			continue
+		}
+
+		batchTracks = append(batchTracks, item)
+		batchMetadata = append(batchMetadata, metadata)
+
+		if !cached {
+			newCount++
+		}
+
+		if (index+1)%metadataBatchSize == 0 {
+			applyMetadata(batchTracks, batchMetadata)
+
+			batchTracks, batchMetadata = nil, nil
+		}
+	}
+
+	if fullScan {
+		pruneMetadata(visitedPaths)
+	}
+
+	applyMetadata(batchTracks, batchMetadata)
+
+	ui.QueueMain(func() {
+		if fullScan {
+			logToEntry("Indexed metadata of %d tracks (%d new)", len(tracks), newCount)
+		}
+
+		// Files moved out of the library keep their settings until a copy shows up again
+
+		if fullScan || newCount != 0 {
+			reattachOrphanedSettings()
+		}
+	})
+
+	if newCount != 0 || fullScan {
+		trySaveMetadata()
+	}
+}
+
+func readTrackMetadata(trackPath string) (*TrackMetadata, bool, error) {
+	fileInfo, statError := os.Stat(trackPath)
+
+	if statError != nil {
+		return nil, false, statError
+	}
+
+	cacheKey := filepath.ToSlash(trackPath)
+
+	g_metadataMutex.Lock()
+	cachedMetadata, exists := g_metadataCache[cacheKey]
+	g_metadataMutex.Unlock()
+
+	if exists && cachedMetadata.Size == fileInfo.Size() && cachedMetadata.ModTime == fileInfo.ModTime().UnixNano() {
+		if cachedMetadata.Hash != "" {
+			return cachedMetadata, true, nil
+		}
+
+		// Entries cached before hashes were added
+
+		fileHash, hashError := hashFile(trackPath)
+
+		if hashError != nil {
+			return nil, false, hashError
+		}
+
+		g_metadataMutex.Lock()
+		cachedMetadata.Hash = fileHash
+		g_metadataMutex.Unlock()
+
+		return cachedMetadata, false, nil
+	}
+
+	fileHash, hashError := hashFile(trackPath)
+
+	if hashError != nil {
+		return nil, false, hashError
+	}
+
+	audioFile, openError := sndfile.Open(trackPath, sndfile.Read, new(sndfile.Info))
+
+	if openError != nil {
+		return nil, false, openError
+	}
+
+	defer audioFile.Close()
+
+	metadata := &TrackMetadata{
+		Size:       fileInfo.Size(),
+		ModTime:    fileInfo.ModTime().UnixNano(),
+		Duration:   0,
+		SampleRate: audioFile.Format.Samplerate,
+		Channels:   audioFile.Format.Channels,
+		Title:      strings.TrimSpace(audioFile.GetString(sndfile.Title)),
+		Artist:     strings.TrimSpace(audioFile.GetString(sndfile.Artist)),
+		Comment:    strings.TrimSpace(audioFile.GetString(sndfile.Comment)),
+		Hash:       fileHash,
+	}
+
+	if metadata.SampleRate > 0 {
+		metadata.Duration = time.Duration(audioFile.Format.Frames) * time.Second / time.Duration(metadata.SampleRate)
+	}
+
+	g_metadataMutex.Lock()
+	g_metadataCache[cacheKey] = metadata
+	g_metadataMutex.Unlock()
+
+	return metadata, false, nil
+}
+
+// pruneMetadata drops the entries of files which no longer exist outside of the scanned tracks.
+
+func pruneMetadata(visitedPaths map[string]bool) {
+	g_metadataMutex.Lock()
+	defer g_metadataMutex.Unlock()
+
+	for key := range g_metadataCache {
+		if visitedPaths[key] {
+			continue
+		}
+
+		if _, statError := os.Stat(filepath.FromSlash(key)); statError == nil {
+			continue
+		}
+
+		delete(g_metadataCache, key)
+	}
+}
+
+func hashFile(trackPath string) (string, error) {
+	audioFile, openError := os.Open(trackPath)
+
+	if openError != nil {
+		return "", openError
+	}
+
+	defer audioFile.Close()
+
+	return hashReader(audioFile)
+}
+
+func hashReader(reader io.Reader) (string, error) {
+	fileHash := sha256.New()
+
+	if _, copyError := io.Copy(fileHash, reader); copyError != nil {
+		return "", copyError
+	}
+
+	return hex.EncodeToString(fileHash.Sum(nil)), nil
+}
+
+func (track *AudioTrack) ApplyMetadata(metadata *TrackMetadata) {
+	track.Metadata = metadata
+	track.Duration = metadata.Duration
+	track.Hash = metadata.Hash
+}
+
+func formatDuration(duration time.Duration) string {
+	if duration <= 0 {
+		return ""
+	}
+
+	totalSeconds := int64(duration.Round(time.Second) / time.Second)
+
+	if totalSeconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", totalSeconds/3600, (totalSeconds/60)%60, totalSeconds%60)
+	}
+
+	return fmt.Sprintf("%d:%02d", totalSeconds/60, totalSeconds%60)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"waveboard/fixes/ui"
+)
+
+// LevelMeter gathers the output levels on the audio thread, which alone touches its fields.
+// Every window of samples is published as a LevelSnapshot, read by the interface without locking.
+
+type LevelMeter struct {
+	Peak       [2]float32
+	SquareSum  [2]float64
+	Samples    int
+	MinGain    float32
+	TotalClips uint64
+	Snapshot   atomic.Pointer[LevelSnapshot]
+}
+
+type LevelSnapshot struct {
+	Peak          [2]float32
+	RMS           [2]float32
+	GainReduction float32
+	Clips         uint64
+	Time          int64
+}
+
+type MeterArea struct{}
+
+const meterWindowsPerSecond = 30
+const meterFloorDB = -60.0
+const meterPeakDecay float32 = 0.85
+
+var g_levelMeter = &LevelMeter{MinGain: 1}
+
+// g_meterDisplay holds the levels drawn by every meter, with a decaying peak.
+// It is only used on the interface thread.
+
+var g_meterDisplay LevelSnapshot
+var g_meterAreas []*ui.Area
+var g_meterOnce sync.Once
+var g_loggedClips uint64
+var g_lastClipLog time.Time
+
+// Add takes a sample before it is clamped, mono samples counting for both channels.
+
+func (meter *LevelMeter) Add(sample float32, channel int, mono bool, gain float32) {
+	level := float32(math.Abs(float64(sample)))
+
+	if level > 1 {
+		meter.TotalClips++
+	}
+
+	if gain < meter.MinGain {
+		meter.MinGain = gain
+	}
+
+	for index := 0; index < 2; index++ {
+		if !mono && index != channel {
+			continue
+		}
+
+		if level > meter.Peak[index] {
+			meter.Peak[index] = level
+		}
+
+		meter.SquareSum[index] += float64(sample) * float64(sample)
+	}
+
+	if mono || channel == 1 {
+		meter.Samples++
+	}
+}
+
+func (meter *LevelMeter) Publish(sampleRate uint32) {
+	if meter.Samples < int(sampleRate)/meterWindowsPerSecond {
+		return
+	}
+
+	snapshot := &LevelSnapshot{
+		Peak:          meter.Peak,
+		GainReduction: float32(-20 * math.Log10(float64(meter.MinGain))),
+		Clips:         meter.TotalClips,
+		Time:          time.Now().UnixNano(),
+	}
+
+	for index := range meter.SquareSum {
+		snapshot.RMS[index] = float32(math.Sqrt(meter.SquareSum[index] / float64(meter.Samples)))
+	}
+
+	meter.Snapshot.Store(snapshot)
+
+	meter.Peak = [2]float32{}
+	meter.SquareSum = [2]float64{}
+	meter.Samples = 0
+	meter.MinGain = 1
+}
+
+func levelToDB(level float32) float64 {
+	if level <= 0 {
+		return meterFloorDB
+	}
+
+	return math.Max(20*math.Log10(float64(level)), meterFloorDB)
+}
+
+// updateMeterDisplay drops snapshots older than a few windows, as nothing is published while silent.
+
+func updateMeterDisplay() {
+	snapshot := g_levelMeter.Snapshot.Load()
+
+	if snapshot == nil || time.Since(time.Unix(0, snapshot.Time)) > 200*time.Millisecond {
+		snapshot = &LevelSnapshot{}
+
+		if current := g_levelMeter.Snapshot.Load(); current != nil {
+			snapshot.Clips = current.Clips
+		}
+	}
+
+	for index := range g_meterDisplay.Peak {
+		if heldPeak := g_meterDisplay.Peak[index] * meterPeakDecay; heldPeak > snapshot.Peak[index] {
+			g_meterDisplay.Peak[index] = heldPeak
+		} else {
+			g_meterDisplay.Peak[index] = snapshot.Peak[index]
+		}
+
+		g_meterDisplay.RMS[index] = snapshot.RMS[index]
+	}
+
+	g_meterDisplay.GainReduction = snapshot.GainReduction
+	g_meterDisplay.Clips = snapshot.Clips
+
+	if g_meterDisplay.Clips > g_loggedClips && time.Since(g_lastClipLog) >= time.Second {
+		logToEntry("Output clipped on %d samples, lower the volume or the limiter threshold", g_meterDisplay.Clips-g_loggedClips)
+
+		g_loggedClips = g_meterDisplay.Clips
+		g_lastClipLog = time.Now()
+	}
+}
+
+func startMeters() {
+	go func() {
+		for range time.Tick(50 * time.Millisecond) {
+			ui.QueueMain(func() {
+				updateMeterDisplay()
+
+				for _, item := range g_meterAreas {
+					item.QueueRedrawAll()
+				}
+			})
+		}
+	}()
+}
+
+func makeMeterStrip() ui.Control {
+	g_meterOnce.Do(startMeters)
+
+	hContainer := ui.NewHorizontalBox()
+	hContainer.SetPadded(true)
+
+	labelsBox := ui.NewVerticalBox()
+	labelsBox.Append(ui.NewLabel("Left"), false)
+	labelsBox.Append(ui.NewLabel("Right"), false)
+	labelsBox.Append(ui.NewLabel("Limiter"), false)
+
+	hContainer.Append(labelsBox, false)
+
+	meterArea := ui.NewArea(&MeterArea{})
+	g_meterAreas = append(g_meterAreas, meterArea)
+
+	hContainer.Append(meterArea, true)
+
+	valuesBox := ui.NewVerticalBox()
+
+	leftLabel := ui.NewLabel("")
+	rightLabel := ui.NewLabel("")
+	limiterLabel := ui.NewLabel("")
+
+	valuesBox.Append(leftLabel, false)
+	valuesBox.Append(rightLabel, false)
+	valuesBox.Append(limiterLabel, false)
+
+	hContainer.Append(valuesBox, false)
+
+	go func() {
+		for range time.Tick(250 * time.Millisecond) {
+			ui.QueueMain(func() {
+				leftLabel.SetText(fmt.Sprintf("%6.1f dB", levelToDB(g_meterDisplay.Peak[0])))
+				rightLabel.SetText(fmt.Sprintf("%6.1f dB", levelToDB(g_meterDisplay.Peak[1])))
+				limiterLabel.SetText(fmt.Sprintf("-%.1f dB, %d clips", g_meterDisplay.GainReduction, g_meterDisplay.Clips))
+			})
+		}
+	}()
+
+	return hContainer
+}
+
+func fillMeterBar(context *ui.DrawContext, x, y, width, height float64, brush *ui.DrawBrush) {
+	if width <= 0 {
+		return
+	}
+
+	barPath := ui.DrawNewPath(ui.DrawFillModeWinding)
+	barPath.AddRectangle(x, y, width, height)
+	barPath.End()
+
+	context.Fill(barPath, brush)
+	barPath.Free()
+}
+
+// Draw shows the peak and RMS of both channels in decibels, then the gain reduction from the right.
+
+func (mh *MeterArea) Draw(a *ui.Area, dp *ui.AreaDrawParams) {
+	rowHeight := dp.AreaHeight / 3
+	barHeight := math.Max(rowHeight-2, 1)
+
+	fillMeterBar(dp.Context, 0, 0, dp.AreaWidth, dp.AreaHeight, &ui.DrawBrush{Type: ui.DrawBrushTypeSolid, R: 0, G: 0, B: 0, A: 0.05})
+
+	for index := range g_meterDisplay.Peak {
+		y := float64(index)*rowHeight + 1
+		peakWidth := (1 - levelToDB(g_meterDisplay.Peak[index])/meterFloorDB) * dp.AreaWidth
+		rmsWidth := (1 - levelToDB(g_meterDisplay.RMS[index])/meterFloorDB) * dp.AreaWidth
+
+		peakBrush := &ui.DrawBrush{Type: ui.DrawBrushTypeSolid, R: 0.3, G: 0.7, B: 0.3, A: 0.6}
+
+		if g_meterDisplay.Peak[index] >= 1 {
+			peakBrush = &ui.DrawBrush{Type: ui.DrawBrushTypeSolid, R: 0.85, G: 0.2, B: 0.2, A: 0.8}
+		}
+
+		fillMeterBar(dp.Context, 0, y, peakWidth, barHeight, peakBrush)
+		fillMeterBar(dp.Context, 0, y+barHeight/4, rmsWidth, barHeight/2, &ui.DrawBrush{Type: ui.DrawBrushTypeSolid, R: 0.1, G: 0.45, B: 0.1, A: 1})
+	}
+
+	reductionWidth := math.Min(float64(g_meterDisplay.GainReduction)/-meterFloorDB, 1) * dp.AreaWidth
+
+	fillMeterBar(dp.Context, dp.AreaWidth-reductionWidth, 2*rowHeight+1, reductionWidth, barHeight,
+		&ui.DrawBrush{Type: ui.DrawBrushTypeSolid, R: 0.9, G: 0.6, B: 0.1, A: 1})
+}
+
+func (mh *MeterArea) MouseEvent(a *ui.Area, me *ui.AreaMouseEvent) {}
+
+func (mh *MeterArea) MouseCrossed(a *ui.Area, left bool) {}
+
+func (mh *MeterArea) DragBroken(a *ui.Area) {}
+
+func (mh *MeterArea) KeyEvent(a *ui.Area, ke *ui.AreaKeyEvent) bool {
+	return false
+}
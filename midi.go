@@ -0,0 +1,694 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"waveboard/fixes/ui"
+)
+
+// MIDI input goes through winmm, which hands every short message to midiInProc as packed bytes.
+// They are fed to a MIDIParser, so another backend would only have to provide the raw byte stream.
+
+type MIDIMessage struct {
+	Kind    byte
+	Channel byte
+	Data1   byte
+	Data2   byte
+}
+
+// MIDIParser decodes channel messages, following running status and skipping system exclusive data.
+
+type MIDIParser struct {
+	Status  byte
+	Data    [2]byte
+	Count   int
+	InSysEx bool
+}
+
+// MIDIControl is a pad or a knob, saved as kind:channel:number.
+
+type MIDIControl struct {
+	Kind    byte
+	Channel byte
+	Number  byte
+}
+
+type MIDIInCaps struct {
+	Mid           uint16
+	Pid           uint16
+	DriverVersion uint32
+	Name          [32]uint16
+	Support       uint32
+}
+
+const (
+	midiNoteOff       = 0x80
+	midiNoteOn        = 0x90
+	midiControlChange = 0xB0
+)
+
+const midiCallbackFunction = 0x30000
+const midiDataMessage = 0x3C3
+const midiEventsSize = 256
+const midiMaxValue = 127
+const midiLimiterFloor float32 = -30.0
+
+var midiTargetsList []string = []string{
+	"globalvolume",
+	"trackvolume",
+	"limiter",
+}
+
+var midiTargetNames map[string]string = map[string]string{
+	"globalvolume": "Global volume",
+	"trackvolume":  "Current track volume",
+	"limiter":      "Limiter threshold",
+}
+
+var midiControlError = errors.New("MIDI : Invalid control, expected note:channel:number or cc:channel:number")
+
+var g_winmm = syscall.NewLazyDLL("winmm.dll")
+var g_midiInGetNumDevs = g_winmm.NewProc("midiInGetNumDevs")
+var g_midiInGetDevCaps = g_winmm.NewProc("midiInGetDevCapsW")
+var g_midiInOpen = g_winmm.NewProc("midiInOpen")
+var g_midiInStart = g_winmm.NewProc("midiInStart")
+var g_midiInStop = g_winmm.NewProc("midiInStop")
+var g_midiInReset = g_winmm.NewProc("midiInReset")
+var g_midiInClose = g_winmm.NewProc("midiInClose")
+
+var g_midiCallback uintptr = syscall.NewCallback(midiInProc)
+var g_midiHandle uintptr
+var g_midiParser MIDIParser
+var g_midiEvents chan MIDIMessage
+
+// g_midiMap maps saved controls to tracks, like g_keysMap does for keys.
+
+var g_midiMap map[string]*AudioTrack = make(map[string]*AudioTrack)
+var g_heldNotes map[MIDIControl]bool = make(map[MIDIControl]bool)
+var g_midiLearnRow int = -1
+var g_midiLearnTarget string
+var g_midiButtons map[string]*ui.Button = make(map[string]*ui.Button)
+
+func midiDataLength(status byte) int {
+	switch status & 0xF0 {
+	case 0xC0, 0xD0:
+		return 1
+	}
+
+	return 2
+}
+
+func (parser *MIDIParser) Feed(value byte) (MIDIMessage, bool) {
+	switch {
+	case value >= 0xF8:
+		// Real-time messages may come between the bytes of any other message
+
+		return MIDIMessage{}, false
+	case value == 0xF0:
+		parser.InSysEx = true
+		parser.Status = 0
+
+		return MIDIMessage{}, false
+	case value >= 0xF0:
+		parser.InSysEx = false
+		parser.Status = 0
+
+		return MIDIMessage{}, false
+	case value >= 0x80:
+		parser.InSysEx = false
+		parser.Status = value
+		parser.Count = 0
+
+		return MIDIMessage{}, false
+	}
+
+	if parser.InSysEx || parser.Status == 0 {
+		return MIDIMessage{}, false
+	}
+
+	parser.Data[parser.Count] = value
+	parser.Count++
+
+	dataLength := midiDataLength(parser.Status)
+
+	if parser.Count < dataLength {
+		return MIDIMessage{}, false
+	}
+
+	parser.Count = 0
+
+	message := MIDIMessage{parser.Status & 0xF0, parser.Status & 0x0F, parser.Data[0], 0}
+
+	if dataLength == 2 {
+		message.Data2 = parser.Data[1]
+	}
+
+	// A note on without velocity is a note off
+
+	if message.Kind == midiNoteOn && message.Data2 == 0 {
+		message.Kind = midiNoteOff
+	}
+
+	return message, true
+}
+
+func (message MIDIMessage) Control() MIDIControl {
+	if message.Kind == midiNoteOff {
+		return MIDIControl{midiNoteOn, message.Channel, message.Data1}
+	}
+
+	return MIDIControl{message.Kind, message.Channel, message.Data1}
+}
+
+func (control MIDIControl) String() string {
+	kindName := "cc"
+
+	if control.Kind == midiNoteOn {
+		kindName = "note"
+	}
+
+	return kindName + ":" + strconv.Itoa(int(control.Channel)+1) + ":" + strconv.Itoa(int(control.Number))
+}
+
+func (control MIDIControl) Label() string {
+	if control.Kind == midiNoteOn {
+		return fmt.Sprintf("Note %d, channel %d", control.Number, control.Channel+1)
+	}
+
+	return fmt.Sprintf("CC %d, channel %d", control.Number, control.Channel+1)
+}
+
+func parseMIDIControl(text string) (MIDIControl, error) {
+	fields := strings.Split(text, ":")
+
+	if len(fields) != 3 {
+		return MIDIControl{}, midiControlError
+	}
+
+	channel, channelError := strconv.ParseUint(fields[1], 10, 8)
+	number, numberError := strconv.ParseUint(fields[2], 10, 8)
+
+	if channelError != nil || numberError != nil || channel < 1 || channel > 16 || number > midiMaxValue {
+		return MIDIControl{}, midiControlError
+	}
+
+	control := MIDIControl{midiControlChange, byte(channel - 1), byte(number)}
+
+	switch fields[0] {
+	case "note":
+		control.Kind = midiNoteOn
+	case "cc":
+	default:
+		return MIDIControl{}, midiControlError
+	}
+
+	return control, nil
+}
+
+func formatMIDIControl(text string) string {
+	control, parseError := parseMIDIControl(text)
+
+	if parseError != nil {
+		return text
+	}
+
+	return control.Label()
+}
+
+// midiInProc runs on a winmm thread and only decodes the message.
+
+func midiInProc(handle, message, instance, param1, param2 uintptr) uintptr {
+	if message != midiDataMessage {
+		return 0
+	}
+
+	packedBytes := [3]byte{byte(param1), byte(param1 >> 8), byte(param1 >> 16)}
+	messageLength := 1
+
+	if packedBytes[0] >= 0x80 && packedBytes[0] < 0xF0 {
+		messageLength += midiDataLength(packedBytes[0])
+	}
+
+	for _, item := range packedBytes[:messageLength] {
+		if decodedMessage, complete := g_midiParser.Feed(item); complete {
+			select {
+			case g_midiEvents <- decodedMessage:
+			default:
+			}
+		}
+	}
+
+	return 0
+}
+
+func listMIDIDevices() []string {
+	devicesCount, _, _ := g_midiInGetNumDevs.Call()
+	devicesList := make([]string, 0, devicesCount)
+
+	for index := uintptr(0); index < devicesCount; index++ {
+		var caps MIDIInCaps
+
+		if result, _, _ := g_midiInGetDevCaps.Call(index, uintptr(unsafe.Pointer(&caps)), unsafe.Sizeof(caps)); result != 0 {
+			devicesList = append(devicesList, "")
+
+			continue
+		}
+
+		devicesList = append(devicesList, syscall.UTF16ToString(caps.Name[:]))
+	}
+
+	return devicesList
+}
+
+func closeMIDIDevice() {
+	if g_midiHandle == 0 {
+		return
+	}
+
+	g_midiInStop.Call(g_midiHandle)
+	g_midiInReset.Call(g_midiHandle)
+	g_midiInClose.Call(g_midiHandle)
+
+	g_midiHandle = 0
+}
+
+func openMIDIDevice(deviceName string) error {
+	closeMIDIDevice()
+
+	if deviceName == "" {
+		return nil
+	}
+
+	deviceIndex := -1
+
+	for index, item := range listMIDIDevices() {
+		if item == deviceName {
+			deviceIndex = index
+
+			break
+		}
+	}
+
+	if deviceIndex == -1 {
+		return errors.New("MIDI : Device " + deviceName + " not found")
+	}
+
+	g_midiParser = MIDIParser{}
+
+	var handle uintptr
+
+	if result, _, _ := g_midiInOpen.Call(uintptr(unsafe.Pointer(&handle)), uintptr(deviceIndex), g_midiCallback, 0, midiCallbackFunction); result != 0 {
+		return fmt.Errorf("MIDI : Could not open %s, error %d", deviceName, result)
+	}
+
+	if result, _, _ := g_midiInStart.Call(handle); result != 0 {
+		g_midiInClose.Call(handle)
+
+		return fmt.Errorf("MIDI : Could not start %s, error %d", deviceName, result)
+	}
+
+	g_midiHandle = handle
+
+	return nil
+}
+
+func setupMIDI() {
+	g_midiEvents = make(chan MIDIMessage, midiEventsSize)
+
+	go midiCallback()
+
+	if openError := openMIDIDevice(g_appSettings.MIDIDevice); openError != nil {
+		logToEntry(openError.Error())
+
+		return
+	}
+
+	if g_appSettings.MIDIDevice != "" {
+		logToEntry("Opened MIDI device %s", g_appSettings.MIDIDevice)
+	}
+}
+
+func cleanMIDI() {
+	closeMIDIDevice()
+}
+
+func midiCallback() {
+	for message := range g_midiEvents {
+		handleMIDIMessage(message)
+	}
+}
+
+func setNoteHeld(control MIDIControl, held bool) {
+	g_heldMutex.Lock()
+	defer g_heldMutex.Unlock()
+
+	if held {
+		g_heldNotes[control] = true
+	} else {
+		delete(g_heldNotes, control)
+	}
+}
+
+func isNoteHeld(control MIDIControl) bool {
+	g_heldMutex.Lock()
+	defer g_heldMutex.Unlock()
+
+	return g_heldNotes[control]
+}
+
+// handleMIDIMessage plays the tracks of pads and applies knobs, unless a control is being learned.
+
+func handleMIDIMessage(message MIDIMessage) {
+	control := message.Control()
+
+	switch message.Kind {
+	case midiNoteOn:
+		if g_midiLearnRow != -1 {
+			ui.QueueMain(func() { learnTrackControl(control) })
+
+			return
+		}
+
+		setNoteHeld(control, true)
+
+		if track, exists := g_midiMap[control.String()]; exists {
+			triggerTrackDown(track, func() bool { return isNoteHeld(control) })
+		}
+	case midiNoteOff:
+		setNoteHeld(control, false)
+
+		if track, exists := g_midiMap[control.String()]; exists {
+			triggerTrackUp(track)
+		}
+	case midiControlChange:
+		if g_midiLearnTarget != "" {
+			ui.QueueMain(func() { learnTargetControl(control) })
+
+			return
+		}
+
+		for _, item := range findMIDITargets(control) {
+			applyMIDIControl(item, message.Data2)
+		}
+	}
+}
+
+// findMIDITargets returns the targets of a knob, which must be on the same channel as the saved control.
+
+func findMIDITargets(control MIDIControl) []string {
+	var targets []string
+
+	for _, item := range midiTargetsList {
+		if g_appSettings.MIDIControls[item] == control.String() {
+			targets = append(targets, item)
+		}
+	}
+
+	return targets
+}
+
+// midiControlValue maps the whole range of a knob onto 0 to 100 % for volumes,
+// and onto -30 to 0 dB for the limiter threshold.
+
+func midiControlValue(target string, value byte) (float32, bool) {
+	ratio := float32(value) / midiMaxValue
+
+	switch target {
+	case "globalvolume", "trackvolume":
+		return ratio * defaultVolume, true
+	case "limiter":
+		return midiLimiterFloor * (1 - ratio), true
+	}
+
+	return 0, false
+}
+
+func applyMIDIControl(target string, value byte) {
+	controlValue, valid := midiControlValue(target, value)
+
+	if !valid {
+		return
+	}
+
+	switch target {
+	case "globalvolume":
+		g_appSettings.GlobalVolume = controlValue
+
+		ui.QueueMain(func() {
+			g_globalVolumeEntry.SetText(strconv.FormatFloat(float64(g_appSettings.GlobalVolume), 'f', 2, 32))
+		})
+	case "trackvolume":
+		track := g_currentTrack
+
+		if track == nil {
+			return
+		}
+
+		track.Volume = controlValue
+
+		if track.ID != -1 {
+			track.SaveSettings()
+		}
+
+		ui.QueueMain(func() { g_filesTableModel.RowChanged(track.GetRow()) })
+	case "limiter":
+		g_appSettings.LimiterThreshold = controlValue
+
+		if g_audioLimiter != nil {
+			g_audioLimiter.Threshold = g_appSettings.LimiterThreshold
+		}
+
+		ui.QueueMain(func() {
+			g_limiterEntry.SetText(strconv.FormatFloat(float64(g_appSettings.LimiterThreshold), 'f', 2, 32))
+		})
+	}
+
+	go trySaveSettings()
+}
+
+func (track *AudioTrack) UnmapMIDI() {
+	if track.MIDIBinding != "" && g_midiMap[track.MIDIBinding] == track {
+		delete(g_midiMap, track.MIDIBinding)
+	}
+}
+
+func cancelMIDILearn() {
+	if g_midiLearnRow != -1 {
+		g_filesTableModel.RowChanged(g_midiLearnRow)
+		g_midiLearnRow = -1
+	}
+
+	if g_midiLearnTarget != "" {
+		target := g_midiLearnTarget
+		g_midiLearnTarget = ""
+
+		updateMIDIButton(target)
+	}
+}
+
+// bindTrackControl binds the pad to the track, taking it away from any other track which is returned.
+
+func bindTrackControl(track *AudioTrack, control MIDIControl) *AudioTrack {
+	controlKey := control.String()
+	boundTrack, exists := g_midiMap[controlKey]
+
+	if exists && boundTrack != track {
+		boundTrack.MIDIBinding = ""
+		boundTrack.SaveSettings()
+	} else {
+		boundTrack = nil
+	}
+
+	track.UnmapMIDI()
+	track.MIDIBinding = controlKey
+	track.SaveSettings()
+
+	g_midiMap[controlKey] = track
+
+	return boundTrack
+}
+
+// learnTrackControl binds the pad to the learning row.
+
+func learnTrackControl(control MIDIControl) {
+	rowTrack := getRowTrack(g_midiLearnRow)
+
+	if rowTrack == nil {
+		g_midiLearnRow = -1
+
+		return
+	}
+
+	if boundTrack := bindTrackControl(rowTrack, control); boundTrack != nil {
+		g_filesTableModel.RowChanged(boundTrack.GetRow())
+	}
+
+	g_filesTableModel.RowChanged(g_midiLearnRow)
+	g_midiLearnRow = -1
+
+	go trySaveSettings()
+}
+
+// toggleTrackLearn starts learning a pad for the row, or unbinds it when the row was already learning.
+
+func toggleTrackLearn(row int) {
+	if g_midiLearnRow == row {
+		rowTrack := getRowTrack(row)
+
+		if rowTrack == nil {
+			g_midiLearnRow = -1
+
+			return
+		}
+
+		rowTrack.UnmapMIDI()
+		rowTrack.MIDIBinding = ""
+		rowTrack.SaveSettings()
+
+		g_midiLearnRow = -1
+		g_filesTableModel.RowChanged(row)
+
+		go trySaveSettings()
+
+		return
+	}
+
+	cancelMIDILearn()
+
+	g_midiLearnRow = row
+}
+
+func learnTargetControl(control MIDIControl) {
+	target := g_midiLearnTarget
+
+	if target == "" {
+		return
+	}
+
+	g_midiLearnTarget = ""
+
+	controlKey := control.String()
+
+	for otherTarget, item := range g_appSettings.MIDIControls {
+		if item == controlKey && otherTarget != target {
+			delete(g_appSettings.MIDIControls, otherTarget)
+
+			updateMIDIButton(otherTarget)
+		}
+	}
+
+	g_appSettings.MIDIControls[target] = controlKey
+
+	updateMIDIButton(target)
+
+	go trySaveSettings()
+}
+
+func updateMIDIButton(target string) {
+	button, exists := g_midiButtons[target]
+
+	if !exists {
+		return
+	}
+
+	if g_midiLearnTarget == target {
+		button.SetText("Move a knob ... (click to unbind)")
+
+		return
+	}
+
+	if control, bound := g_appSettings.MIDIControls[target]; bound {
+		button.SetText(formatMIDIControl(control))
+
+		return
+	}
+
+	button.SetText("Learn MIDI")
+}
+
+func makeMIDITab() ui.Control {
+	vContainer := ui.NewVerticalBox()
+	vContainer.SetPadded(true)
+
+	midiForm := ui.NewForm()
+	midiForm.SetPadded(true)
+
+	devicesComboBox := ui.NewCombobox()
+	devicesComboBox.Append("None")
+	devicesComboBox.SetSelected(0)
+
+	devicesList := listMIDIDevices()
+
+	for index, item := range devicesList {
+		devicesComboBox.Append(item)
+
+		if item != "" && item == g_appSettings.MIDIDevice {
+			devicesComboBox.SetSelected(index + 1)
+		}
+	}
+
+	devicesComboBox.OnSelected(func(c *ui.Combobox) {
+		newDevice := ""
+
+		if selectedItem := c.Selected(); selectedItem > 0 {
+			newDevice = devicesList[selectedItem-1]
+		}
+
+		if newDevice == g_appSettings.MIDIDevice {
+			return
+		}
+
+		g_appSettings.MIDIDevice = newDevice
+
+		if openError := openMIDIDevice(newDevice); openError != nil {
+			logToEntry(openError.Error())
+		}
+
+		go trySaveSettings()
+	})
+
+	midiForm.Append("Input device :", devicesComboBox, false)
+
+	for _, item := range midiTargetsList {
+		target := item
+
+		learnButton := ui.NewButton("")
+		learnButton.OnClicked(func(b *ui.Button) {
+			if g_midiLearnTarget == target {
+				g_midiLearnTarget = ""
+
+				delete(g_appSettings.MIDIControls, target)
+
+				updateMIDIButton(target)
+
+				go trySaveSettings()
+
+				return
+			}
+
+			cancelMIDILearn()
+
+			g_midiLearnTarget = target
+
+			updateMIDIButton(target)
+		})
+
+		g_midiButtons[target] = learnButton
+
+		updateMIDIButton(target)
+
+		midiForm.Append(midiTargetNames[target]+" :", learnButton, false)
+	}
+
+	vContainer.Append(midiForm, false)
+	vContainer.Append(ui.NewHorizontalSeparator(), false)
+	vContainer.Append(ui.NewLabel("Pads are learned from the MIDI column of the audio tab and follow the trigger mode of their track."), false)
+	vContainer.Append(ui.NewLabel("Knobs set volumes from 0 to 100 % and the limiter threshold from -30 to 0 dB."), false)
+
+	return vContainer
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func feedMIDIParser(data ...byte) []MIDIMessage {
+	var parser MIDIParser
+	var messages []MIDIMessage
+
+	for _, item := range data {
+		if message, complete := parser.Feed(item); complete {
+			messages = append(messages, message)
+		}
+	}
+
+	return messages
+}
+
+func TestMIDIParserFeed(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Data     []byte
+		Messages []MIDIMessage
+	}{
+		{
+			"note on",
+			[]byte{0x91, 60, 100},
+			[]MIDIMessage{{midiNoteOn, 1, 60, 100}},
+		},
+		{
+			"running status",
+			[]byte{0x90, 60, 100, 62, 90, 64, 80},
+			[]MIDIMessage{{midiNoteOn, 0, 60, 100}, {midiNoteOn, 0, 62, 90}, {midiNoteOn, 0, 64, 80}},
+		},
+		{
+			"note on without velocity",
+			[]byte{0x90, 60, 100, 60, 0, 0x80, 62, 64},
+			[]MIDIMessage{{midiNoteOn, 0, 60, 100}, {midiNoteOff, 0, 60, 0}, {midiNoteOff, 0, 62, 64}},
+		},
+		{
+			"real-time bytes between data bytes",
+			[]byte{0xB2, 0xF8, 7, 0xFA, 0xFE, 64, 0xF8, 10, 0xFC, 20},
+			[]MIDIMessage{{midiControlChange, 2, 7, 64}, {midiControlChange, 2, 10, 20}},
+		},
+		{
+			"system exclusive data",
+			[]byte{0xF0, 0x7E, 0x7F, 0x06, 0x01, 0xF7, 0xB0, 7, 127},
+			[]MIDIMessage{{midiControlChange, 0, 7, 127}},
+		},
+		{
+			"system exclusive ending running status",
+			[]byte{0x90, 60, 100, 0xF0, 0x01, 0x02, 0xF7, 62, 100},
+			[]MIDIMessage{{midiNoteOn, 0, 60, 100}},
+		},
+		{
+			"real-time bytes inside system exclusive data",
+			[]byte{0xF0, 0x01, 0xF8, 0x02, 0xF7, 0x90, 60, 100},
+			[]MIDIMessage{{midiNoteOn, 0, 60, 100}},
+		},
+		{
+			"system exclusive ended by a status byte",
+			[]byte{0xF0, 0x01, 0x02, 0x9F, 60, 100},
+			[]MIDIMessage{{midiNoteOn, 15, 60, 100}},
+		},
+		{
+			"data without status",
+			[]byte{60, 100, 0xB0, 1, 2},
+			[]MIDIMessage{{midiControlChange, 0, 1, 2}},
+		},
+		{
+			"status interrupting a message",
+			[]byte{0x90, 60, 0xB0, 1, 2},
+			[]MIDIMessage{{midiControlChange, 0, 1, 2}},
+		},
+		{
+			"one data byte messages",
+			[]byte{0xC3, 5, 6, 0xD0, 40},
+			[]MIDIMessage{{0xC0, 3, 5, 0}, {0xC0, 3, 6, 0}, {0xD0, 0, 40, 0}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		if messages := feedMIDIParser(testCase.Data...); !reflect.DeepEqual(messages, testCase.Messages) {
+			t.Errorf("Feed with %s returned %v, want %v", testCase.Name, messages, testCase.Messages)
+		}
+	}
+}
+
+func TestMIDIControl(t *testing.T) {
+	noteOff := MIDIMessage{midiNoteOff, 9, 36, 0}
+
+	if control := noteOff.Control(); control.String() != "note:10:36" {
+		t.Fatalf("Note off was saved as %s, want the control of its note on", control)
+	}
+
+	for _, text := range []string{"note:1:0", "note:16:127", "cc:3:7"} {
+		if control, parseError := parseMIDIControl(text); parseError != nil || control.String() != text {
+			t.Errorf("parseMIDIControl(%q) returned %s, %v", text, control, parseError)
+		}
+	}
+
+	for _, text := range []string{"", "note:0:1", "note:17:1", "cc:1:128", "pitch:1:1", "note:1", "note:a:1"} {
+		if _, parseError := parseMIDIControl(text); parseError != midiControlError {
+			t.Errorf("parseMIDIControl(%q) returned %v, want the control error", text, parseError)
+		}
+	}
+}
+
+func TestMIDIControlValue(t *testing.T) {
+	testCases := []struct {
+		Target string
+		Value  byte
+		Result float32
+		Valid  bool
+	}{
+		{"globalvolume", 0, 0, true},
+		{"globalvolume", 127, 100, true},
+		{"trackvolume", 127, 100, true},
+		{"trackvolume", 0, 0, true},
+		{"limiter", 127, 0, true},
+		{"limiter", 0, -30, true},
+		{"unknown", 127, 0, false},
+	}
+
+	for _, testCase := range testCases {
+		if result, valid := midiControlValue(testCase.Target, testCase.Value); result != testCase.Result || valid != testCase.Valid {
+			t.Errorf("midiControlValue(%q, %d) returned %v, %v, want %v, %v", testCase.Target, testCase.Value, result, valid, testCase.Result, testCase.Valid)
+		}
+	}
+
+	// The middle of the knob is about half of the volume
+
+	if result, _ := midiControlValue("globalvolume", 64); result < 50 || result > 51 {
+		t.Errorf("midiControlValue(\"globalvolume\", 64) returned %v, want about 50", result)
+	}
+}
+
+func TestFindMIDITargets(t *testing.T) {
+	previousControls := g_appSettings.MIDIControls
+
+	defer func() { g_appSettings.MIDIControls = previousControls }()
+
+	g_appSettings.MIDIControls = map[string]string{
+		"globalvolume": "cc:1:7",
+		"trackvolume":  "cc:1:7",
+		"limiter":      "cc:2:7",
+	}
+
+	testCases := []struct {
+		Data    []byte
+		Targets []string
+	}{
+		{[]byte{0xB0, 7, 64}, []string{"globalvolume", "trackvolume"}},
+		{[]byte{0xB1, 7, 64}, []string{"limiter"}},
+		{[]byte{0xB2, 7, 64}, nil},
+		{[]byte{0xB0, 8, 64}, nil},
+		{[]byte{0x90, 7, 64}, nil},
+	}
+
+	for _, testCase := range testCases {
+		message := feedMIDIParser(testCase.Data...)[0]
+
+		if targets := findMIDITargets(message.Control()); !reflect.DeepEqual(targets, testCase.Targets) {
+			t.Errorf("Message %v controls %q, want %q", testCase.Data, targets, testCase.Targets)
+		}
+	}
+}
+
+func TestMIDIPadMapping(t *testing.T) {
+	previousMap := g_midiMap
+
+	defer func() { g_midiMap = previousMap }()
+
+	pad := &AudioTrack{Name: "Airhorn"}
+	g_midiMap = map[string]*AudioTrack{"note:1:36": pad}
+
+	testCases := []struct {
+		Data  []byte
+		Kind  byte
+		Track *AudioTrack
+	}{
+		{[]byte{0x90, 36, 100}, midiNoteOn, pad},
+		{[]byte{0x90, 36, 0}, midiNoteOff, pad},
+		{[]byte{0x80, 36, 64}, midiNoteOff, pad},
+		{[]byte{0x91, 36, 100}, midiNoteOn, nil},
+		{[]byte{0x90, 37, 100}, midiNoteOn, nil},
+		{[]byte{0xB0, 36, 100}, midiControlChange, nil},
+	}
+
+	for _, testCase := range testCases {
+		message := feedMIDIParser(testCase.Data...)[0]
+
+		if message.Kind != testCase.Kind || g_midiMap[message.Control().String()] != testCase.Track {
+			t.Errorf("Message %v is kind %#x for track %v, want %#x for %v", testCase.Data, message.Kind,
+				g_midiMap[message.Control().String()], testCase.Kind, testCase.Track)
+		}
+	}
+}
+
+func TestBindTrackControl(t *testing.T) {
+	previousMap := g_midiMap
+
+	defer func() { g_midiMap = previousMap }()
+
+	g_midiMap = make(map[string]*AudioTrack)
+
+	root := &LibraryRoot{Path: "sounds", Volume: defaultVolume, Tracks: make(map[string]*AudioTrack), TrackIDs: make(map[string]int)}
+	firstTrack := &AudioTrack{Name: "First", Path: filepath.Join("sounds", "First.wav"), Root: root, Volume: defaultVolume}
+	secondTrack := &AudioTrack{Name: "Second", Path: filepath.Join("sounds", "Second.wav"), Root: root, Volume: defaultVolume}
+
+	pad := MIDIControl{midiNoteOn, 0, 36}
+	otherPad := MIDIControl{midiNoteOn, 0, 37}
+
+	if boundTrack := bindTrackControl(firstTrack, pad); boundTrack != nil || firstTrack.MIDIBinding != "note:1:36" || g_midiMap["note:1:36"] != firstTrack {
+		t.Fatalf("Learning a free pad returned %v and bound %q", boundTrack, firstTrack.MIDIBinding)
+	}
+
+	if root.Tracks["First.wav"] != firstTrack {
+		t.Fatal("The learned pad was not saved")
+	}
+
+	if boundTrack := bindTrackControl(secondTrack, pad); boundTrack != firstTrack || firstTrack.MIDIBinding != "" || g_midiMap["note:1:36"] != secondTrack {
+		t.Fatalf("Learning a used pad returned %v, left %q on the first track", boundTrack, firstTrack.MIDIBinding)
+	}
+
+	if _, saved := root.Tracks["First.wav"]; saved {
+		t.Fatal("The track which lost its pad kept its saved settings")
+	}
+
+	if boundTrack := bindTrackControl(secondTrack, otherPad); boundTrack != nil || len(g_midiMap) != 1 || g_midiMap["note:1:37"] != secondTrack {
+		t.Fatalf("Learning another pad kept %d pads mapped", len(g_midiMap))
+	}
+}
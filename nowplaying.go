@@ -0,0 +1,250 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"waveboard/fixes/gosndfile/sndfile"
+	"waveboard/fixes/ui"
+)
+
+// NowPlayingArea draws the waveform of the current track, with the played part highlighted.
+// Clicking it seeks to that position.
+
+type NowPlayingArea struct{}
+
+const waveformPeaks = 200
+const peaksChunkFrames = 4096
+
+var g_nowPlayingArea *ui.Area
+var g_peaksMutex sync.Mutex
+var g_loadingPeaks map[*AudioTrack]bool = make(map[*AudioTrack]bool)
+
+func getTrackPeaks(track *AudioTrack) []float32 {
+	g_peaksMutex.Lock()
+	defer g_peaksMutex.Unlock()
+
+	return track.Peaks
+}
+
+// loadTrackPeaks reads the file once and keeps the loudest sample of every slice of it on the track.
+// It is started for every played track.
+
+func loadTrackPeaks(track *AudioTrack) {
+	g_peaksMutex.Lock()
+
+	if track.Peaks != nil || g_loadingPeaks[track] {
+		g_peaksMutex.Unlock()
+
+		return
+	}
+
+	g_loadingPeaks[track] = true
+
+	g_peaksMutex.Unlock()
+
+	peaks, peaksError := computePeaks(track.Path, waveformPeaks)
+
+	g_peaksMutex.Lock()
+
+	delete(g_loadingPeaks, track)
+
+	// A flat waveform is kept for unreadable files, so they are not read again
+
+	if peaksError != nil {
+		peaks = make([]float32, waveformPeaks)
+	}
+
+	track.Peaks = peaks
+
+	g_peaksMutex.Unlock()
+
+	if peaksError != nil {
+		ui.QueueMain(func() { logToEntry("No waveform for %s%s : %s", track.Name, track.Extension, peaksError.Error()) })
+
+		return
+	}
+
+	ui.QueueMain(func() { g_nowPlayingArea.QueueRedrawAll() })
+}
+
+func computePeaks(trackPath string, peaksCount int) ([]float32, error) {
+	audioFile, openError := sndfile.Open(trackPath, sndfile.Read, new(sndfile.Info))
+
+	if openError != nil {
+		return nil, openError
+	}
+
+	defer audioFile.Close()
+
+	peaks := make([]float32, peaksCount)
+	channels := int64(audioFile.Format.Channels)
+	totalFrames := audioFile.Format.Frames
+
+	if totalFrames <= 0 || channels <= 0 {
+		return peaks, nil
+	}
+
+	samples := make([]float32, peaksChunkFrames*channels)
+
+	var frameIndex int64
+
+	for {
+		readFrames, readError := audioFile.ReadFrames(samples)
+
+		if readFrames <= 0 || readError != nil {
+			break
+		}
+
+		for index, item := range samples[:readFrames*channels] {
+			peakIndex := int((frameIndex + int64(index)/channels) * int64(peaksCount) / totalFrames)
+
+			if peakIndex >= peaksCount {
+				peakIndex = peaksCount - 1
+			}
+
+			if sample := float32(math.Abs(float64(item))); sample > peaks[peakIndex] {
+				peaks[peakIndex] = sample
+			}
+		}
+
+		frameIndex += readFrames
+	}
+
+	return peaks, nil
+}
+
+func makeNowPlayingStrip() ui.Control {
+	hContainer := ui.NewHorizontalBox()
+	hContainer.SetPadded(true)
+
+	labelsBox := ui.NewVerticalBox()
+
+	nameLabel := ui.NewLabel("Nothing is playing")
+	requesterLabel := ui.NewLabel("")
+	timeLabel := ui.NewLabel("")
+
+	labelsBox.Append(nameLabel, false)
+	labelsBox.Append(requesterLabel, false)
+	labelsBox.Append(timeLabel, false)
+
+	hContainer.Append(labelsBox, false)
+
+	g_nowPlayingArea = ui.NewArea(&NowPlayingArea{})
+
+	// The area takes the height of the labels
+
+	hContainer.Append(g_nowPlayingArea, true)
+
+	go func() {
+		for range time.Tick(250 * time.Millisecond) {
+			ui.QueueMain(func() {
+				track, position, duration := getPlaybackPosition()
+
+				g_nowPlayingArea.QueueRedrawAll()
+
+				if track == nil {
+					nameLabel.SetText("Nothing is playing")
+					requesterLabel.SetText("")
+					timeLabel.SetText("")
+
+					return
+				}
+
+				nameLabel.SetText("#" + track.Label() + " " + track.Name)
+
+				if g_currentRequester != "" {
+					requesterLabel.SetText("Requested by " + g_currentRequester)
+				} else {
+					requesterLabel.SetText("")
+				}
+
+				timeText := formatPosition(position) + " / " + formatPosition(duration)
+
+				if g_trackPaused.Load() {
+					timeText += " (paused)"
+				}
+
+				timeLabel.SetText(timeText)
+			})
+		}
+	}()
+
+	return hContainer
+}
+
+func (mh *NowPlayingArea) Draw(a *ui.Area, dp *ui.AreaDrawParams) {
+	backgroundPath := ui.DrawNewPath(ui.DrawFillModeWinding)
+	backgroundPath.AddRectangle(0, 0, dp.AreaWidth, dp.AreaHeight)
+	backgroundPath.End()
+
+	dp.Context.Fill(backgroundPath, &ui.DrawBrush{Type: ui.DrawBrushTypeSolid, R: 0, G: 0, B: 0, A: 0.05})
+	backgroundPath.Free()
+
+	track, position, duration := getPlaybackPosition()
+
+	if track == nil || duration <= 0 || dp.AreaWidth <= 0 {
+		return
+	}
+
+	progress := float64(position) / float64(duration)
+	peaks := getTrackPeaks(track)
+
+	if peaks == nil {
+		peaks = make([]float32, waveformPeaks)
+	}
+
+	barWidth := dp.AreaWidth / float64(len(peaks))
+	centerY := dp.AreaHeight / 2
+
+	playedPath := ui.DrawNewPath(ui.DrawFillModeWinding)
+	remainingPath := ui.DrawNewPath(ui.DrawFillModeWinding)
+
+	for index, item := range peaks {
+		barHeight := math.Max(float64(item)*dp.AreaHeight, 1)
+		barPath := remainingPath
+
+		if float64(index)/float64(len(peaks)) < progress {
+			barPath = playedPath
+		}
+
+		barPath.AddRectangle(float64(index)*barWidth, centerY-barHeight/2, math.Max(barWidth-1, 1), barHeight)
+	}
+
+	playedPath.End()
+	remainingPath.End()
+
+	dp.Context.Fill(playedPath, &ui.DrawBrush{Type: ui.DrawBrushTypeSolid, R: 0.2, G: 0.45, B: 0.8, A: 1})
+	dp.Context.Fill(remainingPath, &ui.DrawBrush{Type: ui.DrawBrushTypeSolid, R: 0.6, G: 0.6, B: 0.6, A: 1})
+
+	playedPath.Free()
+	remainingPath.Free()
+
+	cursorPath := ui.DrawNewPath(ui.DrawFillModeWinding)
+	cursorPath.AddRectangle(progress*dp.AreaWidth, 0, 1, dp.AreaHeight)
+	cursorPath.End()
+
+	dp.Context.Fill(cursorPath, &ui.DrawBrush{Type: ui.DrawBrushTypeSolid, R: 0, G: 0, B: 0, A: 1})
+	cursorPath.Free()
+}
+
+func (mh *NowPlayingArea) MouseEvent(a *ui.Area, me *ui.AreaMouseEvent) {
+	if me.Down != 1 || me.AreaWidth <= 0 {
+		return
+	}
+
+	_, _, duration := getPlaybackPosition()
+
+	seekCurrentTrack(time.Duration(float64(duration)*math.Min(math.Max(me.X/me.AreaWidth, 0), 1)), false)
+
+	a.QueueRedrawAll()
+}
+
+func (mh *NowPlayingArea) MouseCrossed(a *ui.Area, left bool) {}
+
+func (mh *NowPlayingArea) DragBroken(a *ui.Area) {}
+
+func (mh *NowPlayingArea) KeyEvent(a *ui.Area, ke *ui.AreaKeyEvent) bool {
+	return false
+}
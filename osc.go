@@ -0,0 +1,610 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"waveboard/fixes/ui"
+)
+
+// https://opensoundcontrol.stanford.edu/spec-1_0.html
+
+type OSCMessage struct {
+	Address   string
+	Arguments []any
+}
+
+const defaultOSCAddress = "127.0.0.1:9000"
+const oscAddressPrefix = "/waveboard/"
+const oscBundleTag = "#bundle"
+const oscPlayerName = "OSC"
+const oscMaxPacket = 65535
+const oscFeedbackInterval = 100 * time.Millisecond
+
+const oscHelpMessage = `/waveboard/play <id or name> - plays a track
+/waveboard/queue <id or name> - adds a track to the queue
+/waveboard/volume <percent> - sets the global volume
+/waveboard/bank <number> - selects a bank
+/waveboard/skip, stop, pause, resume and seek <time> - control the playback like the hotkeys
+Other addresses are refused, as anyone reaching the port could send them.
+Feedback sends /waveboard/nowplaying (name, requester, position and duration in seconds)
+and /waveboard/levels (left and right peaks and gain reduction in dB).`
+
+// Only playback and transport addresses are accepted, mapped onto their chat commands.
+// OSC controllers trigger pads, so play and volume map onto the commands which apply right away.
+
+var oscCommandAliases map[string]string = map[string]string{
+	"play":   "fplay",
+	"queue":  "play",
+	"volume": "gvolume",
+	"skip":   "skip",
+	"pause":  "pause",
+	"resume": "resume",
+	"seek":   "seek",
+}
+
+// oscActions are the accepted addresses without a chat command.
+
+var oscActions map[string]func(string) = map[string]func(string){
+	"stop": func(arg string) { stopAll() },
+	"bank": func(arg string) {
+		bank, parseError := strconv.ParseUint(arg, 10, 32)
+
+		if parseError == nil && bank != 0 {
+			ui.QueueMain(func() { selectBank(int(bank) - 1) })
+		}
+	},
+}
+
+var oscFormatError = errors.New("OSC : Malformed packet")
+
+var g_oscConnection *net.UDPConn
+var g_oscFeedbackAddress *net.UDPAddr
+var g_oscMutex sync.Mutex
+var g_oscFeedbackOnce sync.Once
+
+func appendOSCString(buffer []byte, text string) []byte {
+	buffer = append(buffer, text...)
+
+	return append(buffer, make([]byte, 4-len(text)%4)...)
+}
+
+func appendOSCBlob(buffer []byte, blob []byte) []byte {
+	buffer = binary.BigEndian.AppendUint32(buffer, uint32(len(blob)))
+	buffer = append(buffer, blob...)
+
+	return append(buffer, make([]byte, (4-len(blob)%4)%4)...)
+}
+
+func (message *OSCMessage) MarshalBinary() ([]byte, error) {
+	typeTags := []byte{','}
+
+	var arguments []byte
+
+	for _, item := range message.Arguments {
+		switch value := item.(type) {
+		case int32:
+			typeTags = append(typeTags, 'i')
+			arguments = binary.BigEndian.AppendUint32(arguments, uint32(value))
+		case int:
+			typeTags = append(typeTags, 'i')
+			arguments = binary.BigEndian.AppendUint32(arguments, uint32(int32(value)))
+		case int64:
+			typeTags = append(typeTags, 'h')
+			arguments = binary.BigEndian.AppendUint64(arguments, uint64(value))
+		case float32:
+			typeTags = append(typeTags, 'f')
+			arguments = binary.BigEndian.AppendUint32(arguments, math.Float32bits(value))
+		case float64:
+			typeTags = append(typeTags, 'd')
+			arguments = binary.BigEndian.AppendUint64(arguments, math.Float64bits(value))
+		case string:
+			typeTags = append(typeTags, 's')
+			arguments = appendOSCString(arguments, value)
+		case []byte:
+			typeTags = append(typeTags, 'b')
+			arguments = appendOSCBlob(arguments, value)
+		case bool:
+			if value {
+				typeTags = append(typeTags, 'T')
+			} else {
+				typeTags = append(typeTags, 'F')
+			}
+		case nil:
+			typeTags = append(typeTags, 'N')
+		default:
+			return nil, errors.New("OSC : Unsupported argument type")
+		}
+	}
+
+	packet := appendOSCString(nil, message.Address)
+	packet = appendOSCString(packet, string(typeTags))
+
+	return append(packet, arguments...), nil
+}
+
+// readOSCString returns the string and the data following its padding.
+
+func readOSCString(data []byte) (string, []byte, error) {
+	end := 0
+
+	for end < len(data) && data[end] != 0 {
+		end++
+	}
+
+	paddedLength := end + 4 - end%4
+
+	if paddedLength > len(data) {
+		return "", nil, oscFormatError
+	}
+
+	return string(data[:end]), data[paddedLength:], nil
+}
+
+func readOSCUint32(data []byte) (uint32, []byte, error) {
+	if len(data) < 4 {
+		return 0, nil, oscFormatError
+	}
+
+	return binary.BigEndian.Uint32(data), data[4:], nil
+}
+
+func readOSCUint64(data []byte) (uint64, []byte, error) {
+	if len(data) < 8 {
+		return 0, nil, oscFormatError
+	}
+
+	return binary.BigEndian.Uint64(data), data[8:], nil
+}
+
+func parseOSCMessage(data []byte) (*OSCMessage, error) {
+	address, data, addressError := readOSCString(data)
+
+	if addressError != nil {
+		return nil, addressError
+	}
+
+	if !strings.HasPrefix(address, "/") {
+		return nil, oscFormatError
+	}
+
+	message := &OSCMessage{Address: address}
+
+	// Type tags are optional in old implementations
+
+	if len(data) == 0 {
+		return message, nil
+	}
+
+	typeTags, data, tagsError := readOSCString(data)
+
+	if tagsError != nil {
+		return nil, tagsError
+	}
+
+	if !strings.HasPrefix(typeTags, ",") {
+		return nil, oscFormatError
+	}
+
+	for _, tag := range typeTags[1:] {
+		var readError error
+
+		switch tag {
+		case 'i':
+			var value uint32
+
+			value, data, readError = readOSCUint32(data)
+			message.Arguments = append(message.Arguments, int32(value))
+		case 'f':
+			var value uint32
+
+			value, data, readError = readOSCUint32(data)
+			message.Arguments = append(message.Arguments, math.Float32frombits(value))
+		case 'h':
+			var value uint64
+
+			value, data, readError = readOSCUint64(data)
+			message.Arguments = append(message.Arguments, int64(value))
+		case 'd':
+			var value uint64
+
+			value, data, readError = readOSCUint64(data)
+			message.Arguments = append(message.Arguments, math.Float64frombits(value))
+		case 's', 'S':
+			var value string
+
+			value, data, readError = readOSCString(data)
+			message.Arguments = append(message.Arguments, value)
+		case 'b':
+			var blobLength uint32
+
+			blobLength, data, readError = readOSCUint32(data)
+
+			paddedLength := int(blobLength) + (4-int(blobLength)%4)%4
+
+			if readError == nil && (blobLength > oscMaxPacket || paddedLength > len(data)) {
+				readError = oscFormatError
+			}
+
+			if readError == nil {
+				message.Arguments = append(message.Arguments, append([]byte(nil), data[:blobLength]...))
+				data = data[paddedLength:]
+			}
+		case 'T':
+			message.Arguments = append(message.Arguments, true)
+		case 'F':
+			message.Arguments = append(message.Arguments, false)
+		case 'N', 'I':
+			message.Arguments = append(message.Arguments, nil)
+		default:
+			readError = errors.New("OSC : Unsupported type tag " + string(tag))
+		}
+
+		if readError != nil {
+			return nil, readError
+		}
+	}
+
+	return message, nil
+}
+
+// ParseOSCPacket flattens bundles, whose time tags are ignored as every message runs when it arrives.
+
+func ParseOSCPacket(data []byte) ([]*OSCMessage, error) {
+	if len(data) == 0 || len(data)%4 != 0 {
+		return nil, oscFormatError
+	}
+
+	if data[0] != '#' {
+		message, parseError := parseOSCMessage(data)
+
+		if parseError != nil {
+			return nil, parseError
+		}
+
+		return []*OSCMessage{message}, nil
+	}
+
+	bundleTag, data, tagError := readOSCString(data)
+
+	if tagError != nil || bundleTag != oscBundleTag || len(data) < 8 {
+		return nil, oscFormatError
+	}
+
+	data = data[8:]
+
+	var messages []*OSCMessage
+
+	for len(data) != 0 {
+		elementSize, elementData, sizeError := readOSCUint32(data)
+
+		if sizeError != nil || int(elementSize) > len(elementData) {
+			return nil, oscFormatError
+		}
+
+		elementMessages, parseError := ParseOSCPacket(elementData[:elementSize])
+
+		if parseError != nil {
+			return nil, parseError
+		}
+
+		messages = append(messages, elementMessages...)
+		data = elementData[elementSize:]
+	}
+
+	return messages, nil
+}
+
+func formatOSCArgument(argument any) string {
+	switch value := argument.(type) {
+	case int32:
+		return strconv.FormatInt(int64(value), 10)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float32:
+		return strconv.FormatFloat(float64(value), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	}
+
+	return ""
+}
+
+// findOSCAction returns what an address runs, or nil for the addresses which are refused.
+
+func findOSCAction(commandName string) func(string) {
+	if action, exists := oscActions[commandName]; exists {
+		return action
+	}
+
+	command, exists := g_logCommands[oscCommandAliases[commandName]]
+
+	if !exists {
+		return nil
+	}
+
+	return func(arg string) { command.Action(oscPlayerName, arg) }
+}
+
+// handleOSCMessage runs the action matching the address as the user OSC.
+// The allowed and blocked lists do not apply, so only the playback controls of the hotkeys are reachable.
+
+func handleOSCMessage(message *OSCMessage) {
+	commandName, found := strings.CutPrefix(message.Address, oscAddressPrefix)
+
+	if !found {
+		return
+	}
+
+	action := findOSCAction(commandName)
+
+	if action == nil {
+		ui.QueueMain(func() { logToEntry("Refused OSC address %s", message.Address) })
+
+		return
+	}
+
+	var arguments []string
+
+	for _, item := range message.Arguments {
+		if argumentText := formatOSCArgument(item); argumentText != "" {
+			arguments = append(arguments, argumentText)
+		}
+	}
+
+	action(strings.Join(arguments, " "))
+}
+
+func oscCallback(connection *net.UDPConn) {
+	packet := make([]byte, oscMaxPacket)
+
+	for {
+		packetSize, _, readError := connection.ReadFromUDP(packet)
+
+		if readError != nil {
+			if errors.Is(readError, net.ErrClosed) {
+				return
+			}
+
+			continue
+		}
+
+		messages, parseError := ParseOSCPacket(packet[:packetSize])
+
+		if parseError != nil {
+			ui.QueueMain(func() { logToEntry(parseError.Error()) })
+
+			continue
+		}
+
+		for _, item := range messages {
+			handleOSCMessage(item)
+		}
+	}
+}
+
+// setupOSC applies the OSC settings, restarting the server when they change.
+
+func setupOSC() {
+	g_oscMutex.Lock()
+	defer g_oscMutex.Unlock()
+
+	if g_oscConnection != nil {
+		g_oscConnection.Close()
+		g_oscConnection = nil
+	}
+
+	g_oscFeedbackAddress = nil
+
+	if !g_appSettings.OSCEnabled {
+		return
+	}
+
+	listenAddress, resolveError := net.ResolveUDPAddr("udp", g_appSettings.OSCAddress)
+
+	if resolveError != nil {
+		logToEntry(resolveError.Error())
+
+		return
+	}
+
+	connection, listenError := net.ListenUDP("udp", listenAddress)
+
+	if listenError != nil {
+		logToEntry(listenError.Error())
+
+		return
+	}
+
+	g_oscConnection = connection
+
+	go oscCallback(connection)
+
+	logToEntry("Listening for OSC messages on %s", connection.LocalAddr().String())
+
+	if g_appSettings.OSCFeedback == "" {
+		return
+	}
+
+	feedbackAddress, resolveError := net.ResolveUDPAddr("udp", g_appSettings.OSCFeedback)
+
+	if resolveError != nil {
+		logToEntry(resolveError.Error())
+
+		return
+	}
+
+	g_oscFeedbackAddress = feedbackAddress
+
+	g_oscFeedbackOnce.Do(func() { go oscFeedbackCallback() })
+}
+
+func cleanOSC() {
+	g_oscMutex.Lock()
+	defer g_oscMutex.Unlock()
+
+	g_oscConnection.Close()
+	g_oscConnection = nil
+}
+
+func sendOSCFeedback(messages ...*OSCMessage) {
+	g_oscMutex.Lock()
+	defer g_oscMutex.Unlock()
+
+	if g_oscConnection == nil || g_oscFeedbackAddress == nil {
+		return
+	}
+
+	for _, item := range messages {
+		packet, marshalError := item.MarshalBinary()
+
+		if marshalError != nil {
+			continue
+		}
+
+		g_oscConnection.WriteToUDP(packet, g_oscFeedbackAddress)
+	}
+}
+
+// oscFeedbackCallback sends the levels on every tick and the current track once per second or when it changes.
+
+func oscFeedbackCallback() {
+	var lastTrack *AudioTrack
+	var ticks int
+
+	for range time.Tick(oscFeedbackInterval) {
+		levels := &LevelSnapshot{}
+
+		if snapshot := g_levelMeter.Snapshot.Load(); snapshot != nil && time.Since(time.Unix(0, snapshot.Time)) <= 200*time.Millisecond {
+			levels = snapshot
+		}
+
+		messages := []*OSCMessage{{
+			Address:   oscAddressPrefix + "levels",
+			Arguments: []any{float32(levelToDB(levels.Peak[0])), float32(levelToDB(levels.Peak[1])), levels.GainReduction},
+		}}
+
+		track, position, duration := getPlaybackPosition()
+
+		if ticks++; track != lastTrack || ticks >= int(time.Second/oscFeedbackInterval) {
+			nowPlaying := &OSCMessage{Address: oscAddressPrefix + "nowplaying", Arguments: []any{"", "", float32(0), float32(0)}}
+
+			if track != nil {
+				nowPlaying.Arguments = []any{track.Name, g_currentRequester, float32(position.Seconds()), float32(duration.Seconds())}
+			}
+
+			messages = append(messages, nowPlaying)
+			lastTrack = track
+			ticks = 0
+		}
+
+		sendOSCFeedback(messages...)
+	}
+}
+
+func makeOSCTab() ui.Control {
+	vContainer := ui.NewVerticalBox()
+	vContainer.SetPadded(true)
+
+	oscForm := ui.NewForm()
+	oscForm.SetPadded(true)
+
+	enabledCheckbox := ui.NewCheckbox("Listen for OSC messages")
+	enabledCheckbox.SetChecked(g_appSettings.OSCEnabled)
+	enabledCheckbox.OnToggled(func(c *ui.Checkbox) {
+		g_appSettings.OSCEnabled = c.Checked()
+
+		setupOSC()
+
+		go trySaveSettings()
+	})
+
+	oscForm.Append("Server :", enabledCheckbox, false)
+
+	listenGrid := ui.NewGrid()
+	listenGrid.SetPadded(true)
+
+	listenEntry := ui.NewEntry()
+	listenEntry.SetText(g_appSettings.OSCAddress)
+
+	listenGrid.Append(listenEntry, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	listenButton := ui.NewButton("Apply new listen address")
+	listenButton.OnClicked(func(b *ui.Button) {
+		newAddress := strings.TrimSpace(listenEntry.Text())
+
+		if newAddress == g_appSettings.OSCAddress {
+			return
+		}
+
+		if _, _, splitError := net.SplitHostPort(newAddress); splitError != nil {
+			logToEntry(splitError.Error())
+
+			listenEntry.SetText(g_appSettings.OSCAddress)
+
+			return
+		}
+
+		g_appSettings.OSCAddress = newAddress
+
+		setupOSC()
+
+		go trySaveSettings()
+	})
+
+	listenGrid.Append(listenButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	oscForm.Append("Listen address :", listenGrid, false)
+
+	feedbackGrid := ui.NewGrid()
+	feedbackGrid.SetPadded(true)
+
+	feedbackEntry := ui.NewEntry()
+	feedbackEntry.SetText(g_appSettings.OSCFeedback)
+
+	feedbackGrid.Append(feedbackEntry, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	feedbackButton := ui.NewButton("Apply new feedback address")
+	feedbackButton.OnClicked(func(b *ui.Button) {
+		newAddress := strings.TrimSpace(feedbackEntry.Text())
+
+		if newAddress == g_appSettings.OSCFeedback {
+			return
+		}
+
+		if _, _, splitError := net.SplitHostPort(newAddress); newAddress != "" && splitError != nil {
+			logToEntry(splitError.Error())
+
+			feedbackEntry.SetText(g_appSettings.OSCFeedback)
+
+			return
+		}
+
+		g_appSettings.OSCFeedback = newAddress
+
+		setupOSC()
+
+		go trySaveSettings()
+	})
+
+	feedbackGrid.Append(feedbackButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	oscForm.Append("Feedback address :", feedbackGrid, false)
+
+	vContainer.Append(oscForm, false)
+	vContainer.Append(ui.NewHorizontalSeparator(), false)
+	vContainer.Append(ui.NewLabel(oscHelpMessage), false)
+	vContainer.Append(ui.NewLabel("Feedback is sent from the listen address, leave it empty to send nothing."), false)
+
+	return vContainer
+}
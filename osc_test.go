@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func makeOSCBundle(elements ...[]byte) []byte {
+	bundle := appendOSCString(nil, oscBundleTag)
+	bundle = binary.BigEndian.AppendUint64(bundle, 1)
+
+	for _, item := range elements {
+		bundle = binary.BigEndian.AppendUint32(bundle, uint32(len(item)))
+		bundle = append(bundle, item...)
+	}
+
+	return bundle
+}
+
+func marshalOSCMessage(t *testing.T, address string, arguments ...any) []byte {
+	packet, marshalError := (&OSCMessage{address, arguments}).MarshalBinary()
+
+	if marshalError != nil {
+		t.Fatal(marshalError)
+	}
+
+	return packet
+}
+
+func TestOSCMessageRoundTrip(t *testing.T) {
+	testCases := []*OSCMessage{
+		{"/waveboard/skip", nil},
+		{"/abc", []any{"abc", "abcd", ""}},
+		{"/waveboard/play", []any{int32(-42), int64(1) << 40, float32(0.5), 2.25, "airhorn"}},
+		{"/blobs", []any{[]byte{1}, []byte{1, 2, 3, 4}, []byte{1, 2, 3, 4, 5}}},
+		{"/flags", []any{true, false, nil}},
+	}
+
+	for _, testCase := range testCases {
+		packet, marshalError := testCase.MarshalBinary()
+
+		if marshalError != nil {
+			t.Errorf("MarshalBinary of %s : %v", testCase.Address, marshalError)
+
+			continue
+		}
+
+		if len(packet)%4 != 0 {
+			t.Errorf("MarshalBinary of %s returned %d bytes, not a multiple of 4", testCase.Address, len(packet))
+		}
+
+		messages, parseError := ParseOSCPacket(packet)
+
+		if parseError != nil {
+			t.Errorf("ParseOSCPacket of %s : %v", testCase.Address, parseError)
+
+			continue
+		}
+
+		if len(messages) != 1 || messages[0].Address != testCase.Address || !reflect.DeepEqual(messages[0].Arguments, testCase.Arguments) {
+			t.Errorf("ParseOSCPacket of %s returned %+v, want %+v", testCase.Address, messages[0], testCase)
+		}
+	}
+
+	// Plain integers are sent as 32 bits
+
+	messages, _ := ParseOSCPacket(marshalOSCMessage(t, "/int", 7))
+
+	if !reflect.DeepEqual(messages[0].Arguments, []any{int32(7)}) {
+		t.Fatalf("An int argument was read back as %#v", messages[0].Arguments)
+	}
+
+	if _, marshalError := (&OSCMessage{"/bad", []any{struct{}{}}}).MarshalBinary(); marshalError == nil {
+		t.Fatal("MarshalBinary accepted an unsupported argument")
+	}
+}
+
+func TestParseOSCPacketErrors(t *testing.T) {
+	validPacket := marshalOSCMessage(t, "/waveboard/play", "airhorn")
+	typeTags := func(tags string) []byte {
+		return appendOSCString(appendOSCString(nil, "/a"), tags)
+	}
+
+	testCases := map[string][]byte{
+		"empty packet":              nil,
+		"length not a multiple":     validPacket[:len(validPacket)-1],
+		"length with extra bytes":   append(append([]byte(nil), validPacket...), 0, 0),
+		"unterminated address":      []byte("/abc"),
+		"relative address":          appendOSCString(nil, "abc"),
+		"tags without comma":        appendOSCString(appendOSCString(nil, "/a"), "is"),
+		"unterminated string":       append(typeTags(",s"), "hell"...),
+		"missing integer":           typeTags(",i"),
+		"missing double":            append(typeTags(",d"), 0, 0, 0, 0),
+		"unsupported type tag":      typeTags(",x"),
+		"oversized blob":            binary.BigEndian.AppendUint32(typeTags(",b"), oscMaxPacket+1),
+		"blob past the end":         append(binary.BigEndian.AppendUint32(typeTags(",b"), 8), 1, 2, 3, 4),
+		"blob with a huge length":   binary.BigEndian.AppendUint32(typeTags(",b"), 0xFFFFFFFF),
+		"wrong bundle tag":          appendOSCString(make([]byte, 0, 16), "#bundlf"),
+		"bundle without time tag":   appendOSCString(nil, oscBundleTag),
+		"bundle element too large":  binary.BigEndian.AppendUint32(makeOSCBundle(), uint32(len(validPacket)+4)),
+		"bundle with a bad element": makeOSCBundle(validPacket, []byte("/abc")),
+	}
+
+	for name, packet := range testCases {
+		if messages, parseError := ParseOSCPacket(packet); parseError == nil {
+			t.Errorf("ParseOSCPacket accepted a packet with %s : %+v", name, messages)
+		}
+	}
+}
+
+func TestParseOSCBundle(t *testing.T) {
+	packet := makeOSCBundle(
+		marshalOSCMessage(t, "/first", int32(1)),
+		makeOSCBundle(
+			marshalOSCMessage(t, "/second", "two"),
+			makeOSCBundle(marshalOSCMessage(t, "/third", float32(3))),
+		),
+		makeOSCBundle(),
+		appendOSCString(nil, "/fourth"),
+	)
+
+	messages, parseError := ParseOSCPacket(packet)
+
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+
+	wantMessages := []*OSCMessage{
+		{"/first", []any{int32(1)}},
+		{"/second", []any{"two"}},
+		{"/third", []any{float32(3)}},
+		{"/fourth", nil},
+	}
+
+	if !reflect.DeepEqual(messages, wantMessages) {
+		t.Fatalf("ParseOSCPacket flattened the nested bundles into %+v", messages)
+	}
+}
+
+func TestOSCLoopback(t *testing.T) {
+	previousCommands := g_logCommands
+
+	defer func() { g_logCommands = previousCommands }()
+
+	receivedCommands := make(chan string, 4)
+	makeCommand := func(commandName string) *LogCommand {
+		return &LogCommand{Action: func(playerName string, arg string) {
+			receivedCommands <- playerName + " " + commandName + " " + arg
+		}}
+	}
+
+	g_logCommands = map[string]*LogCommand{
+		"fplay":   makeCommand("fplay"),
+		"gvolume": makeCommand("gvolume"),
+		"skip":    makeCommand("skip"),
+	}
+
+	connection, listenError := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+
+	if listenError != nil {
+		t.Fatal(listenError)
+	}
+
+	defer connection.Close()
+
+	go oscCallback(connection)
+
+	sender, dialError := net.DialUDP("udp", nil, connection.LocalAddr().(*net.UDPAddr))
+
+	if dialError != nil {
+		t.Fatal(dialError)
+	}
+
+	defer sender.Close()
+
+	// Addresses outside of the prefix are ignored
+
+	packets := [][]byte{
+		marshalOSCMessage(t, "/other/play", "ignored"),
+		marshalOSCMessage(t, "/waveboard/play", "air horn", int32(3)),
+		makeOSCBundle(
+			marshalOSCMessage(t, "/waveboard/volume", float32(50)),
+			marshalOSCMessage(t, "/waveboard/skip"),
+		),
+	}
+
+	for _, item := range packets {
+		if _, writeError := sender.Write(item); writeError != nil {
+			t.Fatal(writeError)
+		}
+	}
+
+	for _, wantCommand := range []string{"OSC fplay air horn 3", "OSC gvolume 50", "OSC skip "} {
+		select {
+		case command := <-receivedCommands:
+			if command != wantCommand {
+				t.Fatalf("OSC ran %q, want %q", command, wantCommand)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("OSC did not run %q", wantCommand)
+		}
+	}
+}
+
+func TestOSCRefusedAddresses(t *testing.T) {
+	previousCommands := g_logCommands
+
+	defer func() { g_logCommands = previousCommands }()
+
+	var ranCommand string
+
+	g_logCommands = make(map[string]*LogCommand)
+
+	for _, commandName := range []string{"play", "fplay", "gvolume", "skip", "skipall", "block", "allow", "removeblock", "removeallow", "fvideo", "macro", "tts"} {
+		commandName := commandName
+
+		g_logCommands[commandName] = &LogCommand{Action: func(playerName string, arg string) { ranCommand = commandName }}
+	}
+
+	for _, address := range []string{"block", "allow", "removeblock", "removeallow", "fvideo", "macro", "skipall", "tts", "fplay", "gvolume", ""} {
+		if findOSCAction(address) != nil {
+			t.Errorf("OSC address %s%s is accepted", oscAddressPrefix, address)
+		}
+	}
+
+	testCases := map[string]string{
+		"play":   "fplay",
+		"queue":  "play",
+		"volume": "gvolume",
+		"skip":   "skip",
+	}
+
+	for address, commandName := range testCases {
+		action := findOSCAction(address)
+
+		if action == nil {
+			t.Errorf("OSC address %s%s is refused", oscAddressPrefix, address)
+
+			continue
+		}
+
+		ranCommand = ""
+
+		if action("x"); ranCommand != commandName {
+			t.Errorf("OSC address %s%s ran %q, want %q", oscAddressPrefix, address, ranCommand, commandName)
+		}
+	}
+}
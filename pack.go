@@ -0,0 +1,483 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"waveboard/fixes/ui"
+
+	"github.com/moutend/go-hook/pkg/types"
+)
+
+type PackManifest struct {
+	Version int          `json:"version"`
+	Name    string       `json:"name"`
+	Tracks  []*PackTrack `json:"tracks"`
+}
+
+type PackTrack struct {
+	File       string       `json:"file"`
+	Name       string       `json:"name"`
+	Volume     float32      `json:"volume"`
+	Binding    types.VKCode `json:"binding"`
+	Trigger    int          `json:"trigger,omitempty"`
+	Bank       int          `json:"bank,omitempty"`
+	Categories []string     `json:"categories,omitempty"`
+}
+
+type ImportedTrack struct {
+	Path  string
+	Entry *PackTrack
+}
+
+const packManifestName = "manifest.json"
+const packAudioFolder = "audio"
+const packVersion = 1
+
+// exportPack removes the zip file when the export fails, instead of leaving a partial pack.
+
+func exportPack(zipPath string, tracks []*AudioTrack) error {
+	if len(tracks) == 0 {
+		return errors.New("No tracks to export")
+	}
+
+	zipFile, createError := os.Create(zipPath)
+
+	if createError != nil {
+		return createError
+	}
+
+	writeError := writePack(zipFile, strings.TrimSuffix(filepath.Base(zipPath), filepath.Ext(zipPath)), tracks)
+
+	if closeError := zipFile.Close(); writeError == nil {
+		writeError = closeError
+	}
+
+	if writeError != nil {
+		os.Remove(zipPath)
+
+		return writeError
+	}
+
+	return nil
+}
+
+func writePack(zipFile io.Writer, packName string, tracks []*AudioTrack) error {
+	zipWriter := zip.NewWriter(zipFile)
+
+	manifest := &PackManifest{
+		Version: packVersion,
+		Name:    packName,
+		Tracks:  make([]*PackTrack, 0, len(tracks)),
+	}
+
+	usedFiles := make(map[string]bool, len(tracks))
+
+	for _, item := range tracks {
+		packFile := path.Join(packAudioFolder, filepath.Base(item.Path))
+
+		if item.Root != nil {
+			packFile = path.Join(packAudioFolder, item.Root.RelativeKey(item.Path))
+		}
+
+		// Tracks of different roots may share a relative path
+
+		baseFile := strings.TrimSuffix(packFile, item.Extension)
+
+		for index := 2; usedFiles[strings.ToLower(packFile)]; index++ {
+			packFile = baseFile + " (" + strconv.Itoa(index) + ")" + item.Extension
+		}
+
+		usedFiles[strings.ToLower(packFile)] = true
+
+		if copyError := writePackFile(zipWriter, packFile, item.Path); copyError != nil {
+			return fmt.Errorf("%s%s : %w", item.Name, item.Extension, copyError)
+		}
+
+		manifest.Tracks = append(manifest.Tracks, &PackTrack{
+			File:       packFile,
+			Name:       item.Name,
+			Volume:     item.Volume,
+			Binding:    item.Binding,
+			Trigger:    item.TriggerMode,
+			Bank:       item.Bank,
+			Categories: item.Categories,
+		})
+	}
+
+	manifestWriter, headerError := zipWriter.Create(packManifestName)
+
+	if headerError != nil {
+		return headerError
+	}
+
+	jsonManifest, marshalError := json.MarshalIndent(manifest, "", "\t")
+
+	if marshalError != nil {
+		return marshalError
+	}
+
+	if _, writeError := manifestWriter.Write(jsonManifest); writeError != nil {
+		return writeError
+	}
+
+	return zipWriter.Close()
+}
+
+func writePackFile(zipWriter *zip.Writer, packFile string, trackPath string) error {
+	audioFile, openError := os.Open(trackPath)
+
+	if openError != nil {
+		return openError
+	}
+
+	defer audioFile.Close()
+
+	// Audio files are already compressed
+
+	fileWriter, headerError := zipWriter.CreateHeader(&zip.FileHeader{
+		Name:   packFile,
+		Method: zip.Store,
+	})
+
+	if headerError != nil {
+		return headerError
+	}
+
+	_, copyError := io.Copy(fileWriter, audioFile)
+
+	return copyError
+}
+
+// extractPack copies the audio of a pack into a folder of the root, named after the pack.
+// Files which already exist with the same contents are reused, others are renamed.
+
+func extractPack(zipPath string, root *LibraryRoot) (*PackManifest, []*ImportedTrack, error) {
+	zipReader, openError := zip.OpenReader(zipPath)
+
+	if openError != nil {
+		return nil, nil, openError
+	}
+
+	defer zipReader.Close()
+
+	manifest, manifestError := readPackManifest(&zipReader.Reader)
+
+	if manifestError != nil {
+		return nil, nil, manifestError
+	}
+
+	packName := manifest.Name
+
+	if packName == "" || !fs.ValidPath(packName) || strings.ContainsAny(packName, `/\:`) || !filepath.IsLocal(packName) {
+		packName = strings.TrimSuffix(filepath.Base(zipPath), filepath.Ext(zipPath))
+	}
+
+	packFolder := filepath.Join(root.FullPath(), packName)
+	importedTracks := make([]*ImportedTrack, 0, len(manifest.Tracks))
+
+	// Every file is checked before any is written, so a bad pack leaves nothing behind
+
+	trackPaths := make([]string, len(manifest.Tracks))
+
+	for index, item := range manifest.Tracks {
+		trackPath, pathError := packTrackPath(packFolder, item.File)
+
+		if pathError != nil {
+			return nil, nil, pathError
+		}
+
+		trackPaths[index] = trackPath
+	}
+
+	for index, item := range manifest.Tracks {
+		trackPath := trackPaths[index]
+
+		extractedPath, extractError := extractPackFile(&zipReader.Reader, item.File, trackPath)
+
+		if extractError != nil {
+			return nil, nil, extractError
+		}
+
+		importedTracks = append(importedTracks, &ImportedTrack{extractedPath, item})
+	}
+
+	return manifest, importedTracks, nil
+}
+
+// packTrackPath returns where a file of the pack is extracted, which is never outside of the pack folder.
+// Backslashes and colons are refused, as Windows reads them as separators and drive or stream names.
+
+func packTrackPath(packFolder string, packFile string) (string, error) {
+	invalidError := fmt.Errorf("Invalid pack file \"%s\"", packFile)
+	relativeFile, inFolder := strings.CutPrefix(packFile, packAudioFolder+"/")
+
+	if !inFolder || !fs.ValidPath(packFile) || strings.ContainsAny(packFile, `\:`) || !extensionsMap[path.Ext(packFile)] {
+		return "", invalidError
+	}
+
+	localPath := filepath.FromSlash(relativeFile)
+
+	if !filepath.IsLocal(localPath) {
+		return "", invalidError
+	}
+
+	trackPath := filepath.Join(packFolder, localPath)
+
+	if !isWithinPath(trackPath, filepath.Clean(packFolder)) {
+		return "", invalidError
+	}
+
+	return trackPath, nil
+}
+
+func readPackManifest(zipReader *zip.Reader) (*PackManifest, error) {
+	manifestFile, openError := zipReader.Open(packManifestName)
+
+	if openError != nil {
+		return nil, fmt.Errorf("Pack has no %s", packManifestName)
+	}
+
+	defer manifestFile.Close()
+
+	manifestContents, readError := io.ReadAll(manifestFile)
+
+	if readError != nil {
+		return nil, readError
+	}
+
+	manifest := &PackManifest{}
+
+	if jsonError := json.Unmarshal(manifestContents, manifest); jsonError != nil {
+		return nil, jsonError
+	}
+
+	if manifest.Version > packVersion {
+		return nil, fmt.Errorf("Pack version %d is newer than the supported version %d", manifest.Version, packVersion)
+	}
+
+	return manifest, nil
+}
+
+func extractPackFile(zipReader *zip.Reader, packFile string, trackPath string) (string, error) {
+	packHash, hashError := hashPackFile(zipReader, packFile)
+
+	if hashError != nil {
+		return "", hashError
+	}
+
+	trackExtension := filepath.Ext(trackPath)
+	basePath := strings.TrimSuffix(trackPath, trackExtension)
+
+	for index := 2; ; index++ {
+		if _, statError := os.Stat(trackPath); statError != nil {
+			break
+		}
+
+		if fileHash, _ := hashFile(trackPath); fileHash == packHash {
+			return trackPath, nil
+		}
+
+		trackPath = basePath + " (" + strconv.Itoa(index) + ")" + trackExtension
+	}
+
+	if dirError := os.MkdirAll(filepath.Dir(trackPath), 0755); dirError != nil {
+		return "", dirError
+	}
+
+	sourceFile, openError := zipReader.Open(packFile)
+
+	if openError != nil {
+		return "", openError
+	}
+
+	defer sourceFile.Close()
+
+	audioFile, createError := os.Create(trackPath)
+
+	if createError != nil {
+		return "", createError
+	}
+
+	if _, copyError := io.Copy(audioFile, sourceFile); copyError != nil {
+		audioFile.Close()
+		os.Remove(trackPath)
+
+		return "", copyError
+	}
+
+	return trackPath, audioFile.Close()
+}
+
+func hashPackFile(zipReader *zip.Reader, packFile string) (string, error) {
+	sourceFile, openError := zipReader.Open(packFile)
+
+	if openError != nil {
+		return "", openError
+	}
+
+	defer sourceFile.Close()
+
+	return hashReader(sourceFile)
+}
+
+// applyPackSettings saves the volumes and bindings of the imported files before they are scanned.
+// Bindings already used by other tracks of the same bank are kept and the imported ones are dropped.
+// Banks above the bank count fall back to the first one.
+
+func applyPackSettings(root *LibraryRoot, importedTracks []*ImportedTrack) []string {
+	var conflicts []string
+
+	usedBindings := make(map[BankKey]bool)
+
+	for _, item := range importedTracks {
+		savedTrack := &AudioTrack{
+			Root:        root,
+			Volume:      item.Entry.Volume,
+			Binding:     item.Entry.Binding,
+			TriggerMode: item.Entry.Trigger,
+			Bank:        item.Entry.Bank,
+			Categories:  parseCategories(strings.Join(item.Entry.Categories, ",")),
+		}
+
+		if savedTrack.Volume < 0 {
+			savedTrack.Volume = root.Volume
+		}
+
+		if savedTrack.Bank < 0 || savedTrack.Bank >= g_appSettings.BankCount {
+			savedTrack.Bank = 0
+		}
+
+		if savedTrack.Binding != 0 {
+			bankKey := BankKey{savedTrack.Bank, savedTrack.Binding}
+			boundTrack := findBankTrack(bankKey.Bank, bankKey.Key)
+
+			if (boundTrack != nil && boundTrack.Path != item.Path) || usedBindings[bankKey] || savedTrack.Binding == deleteKey ||
+				findTransportAction(savedTrack.Binding) != "" {
+				conflicts = append(conflicts, item.Entry.Name+" ("+formatKey(savedTrack.Binding)+")")
+
+				savedTrack.Binding = 0
+				savedTrack.Bank = 0
+			} else {
+				usedBindings[bankKey] = true
+			}
+		}
+
+		settingsKey := root.RelativeKey(item.Path)
+
+		// Identical files which were already in the library keep their settings
+
+		if _, exists := root.Tracks[settingsKey]; exists || savedTrack.IsDefault() {
+			continue
+		}
+
+		root.Tracks[settingsKey] = savedTrack
+	}
+
+	return conflicts
+}
+
+func tryImportPack(zipPath string) {
+	root := getDownloadRoot()
+
+	if root == nil {
+		ui.QueueMain(func() { logToEntry("No audio directory found") })
+
+		return
+	}
+
+	manifest, importedTracks, importError := extractPack(zipPath, root)
+
+	if importError != nil {
+		ui.QueueMain(func() { logToEntry(importError.Error()) })
+
+		return
+	}
+
+	ui.QueueMain(func() {
+		conflicts := applyPackSettings(root, importedTracks)
+
+		logToEntry("Imported %d tracks from pack \"%s\" into %s", len(importedTracks), manifest.Name, root.Path)
+
+		if len(conflicts) != 0 {
+			logToEntry("Skipped bindings already in use : %s", strings.Join(conflicts, ", "))
+		}
+
+		rescanLibraries()
+
+		go trySaveSettings()
+	})
+}
+
+func getExportTracks(filteredOnly bool) []*AudioTrack {
+	if !filteredOnly || g_filteredList == nil {
+		return append([]*AudioTrack(nil), g_tracksList...)
+	}
+
+	tracks := make([]*AudioTrack, 0, len(g_filteredList))
+
+	for _, index := range g_filteredList {
+		tracks = append(tracks, g_tracksList[index])
+	}
+
+	return tracks
+}
+
+func makePackBox() ui.Control {
+	packBox := ui.NewHorizontalBox()
+	packBox.SetPadded(true)
+
+	filteredCheckbox := ui.NewCheckbox("Export only the tracks matching the search")
+
+	exportButton := ui.NewButton("Export pack")
+	exportButton.OnClicked(func(b *ui.Button) {
+		zipPath := ui.SaveFile(g_mainWindow)
+
+		if zipPath == "" {
+			return
+		}
+
+		if filepath.Ext(zipPath) == "" {
+			zipPath += ".zip"
+		}
+
+		tracks := getExportTracks(filteredCheckbox.Checked())
+
+		go func() {
+			if exportError := exportPack(zipPath, tracks); exportError != nil {
+				ui.QueueMain(func() { logToEntry(exportError.Error()) })
+
+				return
+			}
+
+			ui.QueueMain(func() { logToEntry("Exported %d tracks to %s", len(tracks), zipPath) })
+		}()
+	})
+
+	packBox.Append(exportButton, false)
+
+	importButton := ui.NewButton("Import pack")
+	importButton.OnClicked(func(b *ui.Button) {
+		zipPath := ui.OpenFile(g_mainWindow)
+
+		if zipPath == "" {
+			return
+		}
+
+		go tryImportPack(zipPath)
+	})
+
+	packBox.Append(importButton, false)
+	packBox.Append(filteredCheckbox, false)
+
+	return packBox
+}
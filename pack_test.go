@@ -0,0 +1,343 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/moutend/go-hook/pkg/types"
+)
+
+func makePackRoot(t *testing.T) *LibraryRoot {
+	return &LibraryRoot{
+		Path:     filepath.ToSlash(t.TempDir()),
+		Enabled:  true,
+		Volume:   defaultVolume,
+		Tracks:   make(map[string]*AudioTrack),
+		TrackIDs: make(map[string]int),
+	}
+}
+
+func writePackTrack(t *testing.T, root *LibraryRoot, relativePath string, contents string) *AudioTrack {
+	trackPath := filepath.Join(root.FullPath(), filepath.FromSlash(relativePath))
+
+	if dirError := os.MkdirAll(filepath.Dir(trackPath), 0755); dirError != nil {
+		t.Fatal(dirError)
+	}
+
+	if writeError := os.WriteFile(trackPath, []byte(contents), 0644); writeError != nil {
+		t.Fatal(writeError)
+	}
+
+	extension := filepath.Ext(trackPath)
+
+	return &AudioTrack{
+		Name:      strings.TrimSuffix(filepath.Base(trackPath), extension),
+		Extension: extension,
+		Path:      trackPath,
+		Root:      root,
+		Volume:    defaultVolume,
+	}
+}
+
+func writePackZip(t *testing.T, manifest *PackManifest, files map[string]string) string {
+	zipPath := filepath.Join(t.TempDir(), "crafted.zip")
+	zipFile, createError := os.Create(zipPath)
+
+	if createError != nil {
+		t.Fatal(createError)
+	}
+
+	defer zipFile.Close()
+
+	zipWriter := zip.NewWriter(zipFile)
+	jsonManifest, _ := json.Marshal(manifest)
+
+	files[packManifestName] = string(jsonManifest)
+
+	for name, contents := range files {
+		fileWriter, headerError := zipWriter.Create(name)
+
+		if headerError != nil {
+			t.Fatal(headerError)
+		}
+
+		fileWriter.Write([]byte(contents))
+	}
+
+	if closeError := zipWriter.Close(); closeError != nil {
+		t.Fatal(closeError)
+	}
+
+	return zipPath
+}
+
+// usePackSettings isolates the globals read when applying the settings of a pack.
+
+func usePackSettings(t *testing.T) {
+	previousSettings, previousTracks := g_appSettings, g_tracksList
+
+	g_appSettings.BankCount = 4
+	g_appSettings.TransportKeys = make(map[string]types.VKCode)
+	g_tracksList = nil
+
+	t.Cleanup(func() {
+		g_appSettings, g_tracksList = previousSettings, previousTracks
+	})
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	usePackSettings(t)
+
+	firstRoot, secondRoot, thirdRoot := makePackRoot(t), makePackRoot(t), makePackRoot(t)
+
+	airhorn := writePackTrack(t, firstRoot, "Airhorn.wav", "first airhorn")
+	airhorn.Volume = 80
+	airhorn.Binding = types.VK_F1
+	airhorn.TriggerMode = triggerHold
+	airhorn.Categories = []string{"memes", "horns"}
+
+	victory := writePackTrack(t, firstRoot, "sfx/Victory.ogg", "victory")
+	victory.Volume = 120
+	victory.Binding = types.VK_F2
+	victory.Bank = 2
+	victory.Categories = []string{"wins"}
+
+	// Tracks of other roots with the same relative path are renamed from the original name
+
+	otherAirhorn := writePackTrack(t, secondRoot, "Airhorn.wav", "second airhorn")
+	otherAirhorn.Volume = 60
+
+	lastAirhorn := writePackTrack(t, thirdRoot, "Airhorn.wav", "third airhorn")
+
+	tracks := []*AudioTrack{airhorn, victory, otherAirhorn, lastAirhorn}
+	zipPath := filepath.Join(t.TempDir(), "Round trip.zip")
+
+	if exportError := exportPack(zipPath, tracks); exportError != nil {
+		t.Fatal(exportError)
+	}
+
+	targetRoot := makePackRoot(t)
+	manifest, importedTracks, importError := extractPack(zipPath, targetRoot)
+
+	if importError != nil {
+		t.Fatal(importError)
+	}
+
+	if manifest.Name != "Round trip" || len(importedTracks) != len(tracks) {
+		t.Fatalf("Imported pack %q with %d tracks, want \"Round trip\" with %d", manifest.Name, len(importedTracks), len(tracks))
+	}
+
+	wantFiles := []string{"audio/Airhorn.wav", "audio/sfx/Victory.ogg", "audio/Airhorn (2).wav", "audio/Airhorn (3).wav"}
+
+	for index, item := range importedTracks {
+		if item.Entry.File != wantFiles[index] {
+			t.Errorf("Track %d was packed as %q, want %q", index, item.Entry.File, wantFiles[index])
+		}
+	}
+
+	if conflicts := applyPackSettings(targetRoot, importedTracks); len(conflicts) != 0 {
+		t.Fatalf("Round trip reported binding conflicts : %v", conflicts)
+	}
+
+	for index, item := range importedTracks {
+		sourceTrack := tracks[index]
+
+		if item.Entry.Name != sourceTrack.Name {
+			t.Errorf("Track %d is named %q, want %q", index, item.Entry.Name, sourceTrack.Name)
+		}
+
+		if !isWithinPath(item.Path, filepath.Join(targetRoot.FullPath(), "Round trip")) {
+			t.Errorf("Track %d was extracted to %s, outside of the pack folder", index, item.Path)
+		}
+
+		sourceContents, _ := os.ReadFile(sourceTrack.Path)
+
+		if importedContents, _ := os.ReadFile(item.Path); string(importedContents) != string(sourceContents) {
+			t.Errorf("Track %d has contents %q, want %q", index, importedContents, sourceContents)
+		}
+
+		savedTrack := targetRoot.Tracks[targetRoot.RelativeKey(item.Path)]
+
+		if sourceTrack.IsDefault() {
+			if savedTrack != nil {
+				t.Errorf("Track %d has default settings but they were saved", index)
+			}
+
+			continue
+		}
+
+		if savedTrack == nil {
+			t.Errorf("Track %d settings were not saved", index)
+
+			continue
+		}
+
+		if savedTrack.Volume != sourceTrack.Volume || savedTrack.Binding != sourceTrack.Binding ||
+			savedTrack.Bank != sourceTrack.Bank || savedTrack.TriggerMode != sourceTrack.TriggerMode ||
+			!reflect.DeepEqual(savedTrack.Categories, sourceTrack.Categories) {
+			t.Errorf("Track %d settings are %+v, want %+v", index, savedTrack, sourceTrack)
+		}
+	}
+
+	// Importing the same pack again reuses the identical files
+
+	_, reimportedTracks, reimportError := extractPack(zipPath, targetRoot)
+
+	if reimportError != nil {
+		t.Fatal(reimportError)
+	}
+
+	for index, item := range reimportedTracks {
+		if item.Path != importedTracks[index].Path {
+			t.Errorf("Reimported track %d went to %s instead of reusing %s", index, item.Path, importedTracks[index].Path)
+		}
+	}
+}
+
+func TestPackBindingConflicts(t *testing.T) {
+	usePackSettings(t)
+
+	targetRoot := makePackRoot(t)
+
+	g_tracksList = []*AudioTrack{{Name: "Existing", Path: filepath.Join(targetRoot.FullPath(), "Existing.wav"), Binding: types.VK_F1}}
+	g_appSettings.TransportKeys["skip"] = types.VK_F3
+
+	packTracks := []*PackTrack{
+		{File: "audio/Taken.wav", Name: "Taken", Volume: 50, Binding: types.VK_F1},
+		{File: "audio/Other bank.wav", Name: "Other bank", Volume: 50, Binding: types.VK_F1, Bank: 1},
+		{File: "audio/First.wav", Name: "First", Volume: 50, Binding: types.VK_F2},
+		{File: "audio/Duplicate.wav", Name: "Duplicate", Volume: 50, Binding: types.VK_F2},
+		{File: "audio/Hotkey.wav", Name: "Hotkey", Volume: 50, Binding: types.VK_F3},
+		{File: "audio/Missing bank.wav", Name: "Missing bank", Volume: 50, Binding: types.VK_F4, Bank: 9},
+	}
+
+	packFiles := make(map[string]string)
+
+	for _, item := range packTracks {
+		packFiles[item.File] = item.Name
+	}
+
+	zipPath := writePackZip(t, &PackManifest{Version: packVersion, Name: "Conflicts", Tracks: packTracks}, packFiles)
+
+	_, importedTracks, importError := extractPack(zipPath, targetRoot)
+
+	if importError != nil {
+		t.Fatal(importError)
+	}
+
+	conflicts := applyPackSettings(targetRoot, importedTracks)
+
+	if strings.Join(conflicts, ", ") != "Taken (F1), Duplicate (F2), Hotkey (F3)" {
+		t.Fatalf("applyPackSettings reported conflicts %v", conflicts)
+	}
+
+	wantBindings := []BankKey{{0, 0}, {1, types.VK_F1}, {0, types.VK_F2}, {0, 0}, {0, 0}, {0, types.VK_F4}}
+
+	for index, item := range importedTracks {
+		savedTrack := targetRoot.Tracks[targetRoot.RelativeKey(item.Path)]
+
+		if savedTrack == nil {
+			t.Fatalf("%s settings were not saved", item.Entry.Name)
+		}
+
+		if (BankKey{savedTrack.Bank, savedTrack.Binding}) != wantBindings[index] {
+			t.Errorf("%s is bound to %v, want %v", item.Entry.Name, BankKey{savedTrack.Bank, savedTrack.Binding}, wantBindings[index])
+		}
+
+		if savedTrack.Volume != 50 {
+			t.Errorf("%s lost its volume when its binding was dropped", item.Entry.Name)
+		}
+	}
+}
+
+func TestPackTrackPath(t *testing.T) {
+	packFolder := filepath.Join(t.TempDir(), "Pack")
+
+	invalidFiles := []string{
+		`audio/..\..\..\evil.wav`,
+		`audio/sub\..\..\evil.wav`,
+		"audio/../evil.wav",
+		"audio/sub/../../evil.wav",
+		"audio/C:evil.wav",
+		"audio/evil.wav:stream.wav",
+		"/audio/evil.wav",
+		"audio//evil.wav",
+		"audio/evil.exe",
+		"sounds/evil.wav",
+		"audio/",
+	}
+
+	for _, item := range invalidFiles {
+		if trackPath, pathError := packTrackPath(packFolder, item); pathError == nil {
+			t.Errorf("packTrackPath accepted %q as %s", item, trackPath)
+		}
+	}
+
+	trackPath, pathError := packTrackPath(packFolder, "audio/sfx/Victory.ogg")
+
+	if pathError != nil || trackPath != filepath.Join(packFolder, "sfx", "Victory.ogg") {
+		t.Fatalf("packTrackPath returned %s, %v for a valid file", trackPath, pathError)
+	}
+}
+
+func TestPackRejectsUnsafeFiles(t *testing.T) {
+	usePackSettings(t)
+
+	targetRoot := makePackRoot(t)
+	evilFile := `audio/..\..\evil.wav`
+
+	zipPath := writePackZip(t, &PackManifest{
+		Version: packVersion,
+		Name:    "Evil",
+		Tracks: []*PackTrack{
+			{File: "audio/Fine.wav", Name: "Fine"},
+			{File: evilFile, Name: "Evil"},
+		},
+	}, map[string]string{"audio/Fine.wav": "fine", evilFile: "evil"})
+
+	if _, _, importError := extractPack(zipPath, targetRoot); importError == nil {
+		t.Fatal("extractPack accepted a file escaping the pack folder")
+	}
+
+	// Nothing is written when any file of the pack is refused
+
+	if _, statError := os.Stat(filepath.Join(targetRoot.FullPath(), "Evil")); !os.IsNotExist(statError) {
+		t.Fatalf("extractPack wrote into the pack folder before refusing the pack : %v", statError)
+	}
+
+	// A manifest name escaping the root falls back to the zip name
+
+	zipPath = writePackZip(t, &PackManifest{Version: packVersion, Name: "..", Tracks: []*PackTrack{{File: "audio/Fine.wav", Name: "Fine"}}},
+		map[string]string{"audio/Fine.wav": "fine"})
+
+	_, importedTracks, importError := extractPack(zipPath, targetRoot)
+
+	if importError != nil {
+		t.Fatal(importError)
+	}
+
+	if importedTracks[0].Path != filepath.Join(targetRoot.FullPath(), "crafted", "Fine.wav") {
+		t.Fatalf("Pack named \"..\" was extracted to %s", importedTracks[0].Path)
+	}
+}
+
+func TestExportPackRemovesFailedZip(t *testing.T) {
+	root := makePackRoot(t)
+	track := writePackTrack(t, root, "Airhorn.wav", "airhorn")
+	missingTrack := &AudioTrack{Name: "Missing", Extension: ".wav", Path: filepath.Join(root.FullPath(), "Missing.wav"), Root: root}
+
+	zipPath := filepath.Join(t.TempDir(), "Failed.zip")
+
+	if exportError := exportPack(zipPath, []*AudioTrack{track, missingTrack}); exportError == nil {
+		t.Fatal("exportPack succeeded with a missing file")
+	}
+
+	if _, statError := os.Stat(zipPath); !os.IsNotExist(statError) {
+		t.Fatalf("exportPack left a partial zip behind : %v", statError)
+	}
+}
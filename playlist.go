@@ -0,0 +1,426 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"waveboard/fixes/ui"
+)
+
+// Playlists reference tracks by their ID, as IDs survive rescans and moves.
+
+type Playlist struct {
+	Tracks  []string `json:"tracks"`
+	Shuffle bool     `json:"shuffle"`
+}
+
+type PlaylistsTableModel struct{}
+
+var g_collectionQuery QueryNode
+var g_collectionComboBox *ui.EditableCombobox
+var g_collectionItems map[string]bool = make(map[string]bool)
+var g_playlistsModel *ui.TableModel
+var g_playlistNames []string
+
+func normalizeCategory(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func parseCategories(text string) []string {
+	var categories []string
+
+	seen := make(map[string]bool)
+
+	for _, item := range strings.Split(text, ",") {
+		category := normalizeCategory(item)
+
+		if category == "" || seen[category] {
+			continue
+		}
+
+		seen[category] = true
+		categories = append(categories, category)
+	}
+
+	return categories
+}
+
+func (track *AudioTrack) HasCategory(name string) bool {
+	category := normalizeCategory(name)
+
+	for _, item := range track.Categories {
+		if item == category {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getCategoriesList() []string {
+	seen := make(map[string]bool)
+
+	var categories []string
+
+	for _, track := range g_tracksList {
+		for _, item := range track.Categories {
+			if seen[item] {
+				continue
+			}
+
+			seen[item] = true
+			categories = append(categories, item)
+		}
+	}
+
+	sort.Strings(categories)
+
+	return categories
+}
+
+func getCategoryTracks(name string) []*AudioTrack {
+	var tracks []*AudioTrack
+
+	for _, item := range g_tracksList {
+		if item.HasCategory(name) {
+			tracks = append(tracks, item)
+		}
+	}
+
+	return tracks
+}
+
+func findPlaylist(name string) (string, *Playlist) {
+	for key, value := range g_appSettings.Playlists {
+		if strings.EqualFold(key, strings.TrimSpace(name)) {
+			return key, value
+		}
+	}
+
+	return "", nil
+}
+
+// Tracks which were deleted are skipped, so playlists keep working without them.
+
+func (playlist *Playlist) GetTracks() []*AudioTrack {
+	tracks := make([]*AudioTrack, 0, len(playlist.Tracks))
+
+	for _, item := range playlist.Tracks {
+		if track := getTrackByLabel(item); track != nil {
+			tracks = append(tracks, track)
+		}
+	}
+
+	return tracks
+}
+
+func (playlist *Playlist) Contains(track *AudioTrack) bool {
+	for _, item := range playlist.Tracks {
+		if strings.EqualFold(item, track.Label()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func shuffleTracks(tracks []*AudioTrack) {
+	g_randomPicker.Shuffle(tracks)
+}
+
+// queueTracks adds as many tracks as the queue limits allow, returning how many were added.
+
+func queueTracks(tracks []*AudioTrack, requester string) int {
+	queuedCount := 0
+
+	for _, item := range tracks {
+		if g_audioQueue.IsFull(g_appSettings.QueueLimit) || g_audioQueue.HasReachedQuota(requester, g_appSettings.UserQueueLimit) {
+			break
+		}
+
+		item.Queue(requester)
+
+		queuedCount++
+	}
+
+	return queuedCount
+}
+
+func playlistCommand(playerName string, arg string) {
+	if arg == "" {
+		return
+	}
+
+	playlistName, playlist := findPlaylist(arg)
+
+	if playlist == nil {
+		sendReply("playlist.notfound", "{user}", playerName, "{arg}", arg)
+
+		return
+	}
+
+	tracks := playlist.GetTracks()
+
+	if len(tracks) == 0 {
+		sendReply("playlist.empty", "{user}", playerName, "{name}", playlistName)
+
+		return
+	}
+
+	if playlist.Shuffle {
+		shuffleTracks(tracks)
+	}
+
+	queuedCount := queueTracks(tracks, playerName)
+
+	if queuedCount == 0 && g_audioQueue.HasReachedQuota(playerName, g_appSettings.UserQueueLimit) {
+		sendReply("playlist.quota", "{user}", playerName, "{limit}", strconv.Itoa(g_appSettings.UserQueueLimit))
+
+		return
+	}
+
+	if queuedCount == 0 {
+		sendReply("playlist.queuefull", "{user}", playerName, "{limit}", strconv.Itoa(g_appSettings.QueueLimit))
+
+		return
+	}
+
+	sendReply("playlist.queued", "{user}", playerName, "{name}", playlistName, "{count}", strconv.Itoa(queuedCount))
+}
+
+// collectionQuery turns the text of the filter box into a query.
+// Categories are tried before playlists when a name is used by both.
+
+func collectionQuery(text string) (QueryNode, error) {
+	name := strings.TrimSpace(text)
+
+	if name == "" {
+		return nil, nil
+	}
+
+	if categoryName, isCategory := strings.CutPrefix(name, "category: "); isCategory {
+		return &QueryTerm{"cat", categoryName, func(track *AudioTrack) bool { return track.HasCategory(categoryName) }}, nil
+	}
+
+	if playlistName, isPlaylist := strings.CutPrefix(name, "playlist: "); isPlaylist {
+		name = playlistName
+	} else {
+		for _, item := range g_tracksList {
+			if item.HasCategory(name) {
+				return &QueryTerm{"cat", name, func(track *AudioTrack) bool { return track.HasCategory(name) }}, nil
+			}
+		}
+	}
+
+	_, playlist := findPlaylist(name)
+
+	if playlist == nil {
+		return nil, errors.New("no category or playlist named \"" + name + "\"")
+	}
+
+	return &QueryTerm{"playlist", name, playlist.Contains}, nil
+}
+
+// updateCollectionItems adds new categories and playlists to the filter box, which can not remove items.
+
+func updateCollectionItems() {
+	if g_collectionComboBox == nil {
+		return
+	}
+
+	for _, item := range getCategoriesList() {
+		if g_collectionItems["category: "+item] {
+			continue
+		}
+
+		g_collectionItems["category: "+item] = true
+		g_collectionComboBox.Append("category: " + item)
+	}
+
+	for _, item := range getPlaylistNames() {
+		if g_collectionItems["playlist: "+item] {
+			continue
+		}
+
+		g_collectionItems["playlist: "+item] = true
+		g_collectionComboBox.Append("playlist: " + item)
+	}
+}
+
+func getPlaylistNames() []string {
+	playlistNames := make([]string, 0, len(g_appSettings.Playlists))
+
+	for key := range g_appSettings.Playlists {
+		playlistNames = append(playlistNames, key)
+	}
+
+	sort.Strings(playlistNames)
+
+	return playlistNames
+}
+
+func refreshPlaylists() {
+	g_playlistNames = getPlaylistNames()
+
+	if g_playlistsModel != nil {
+		g_playlistsModel.RowInserted(0)
+	}
+
+	updateCollectionItems()
+}
+
+func makePlaylistsTab() ui.Control {
+	vContainer := ui.NewVerticalBox()
+	vContainer.SetPadded(true)
+
+	playlistForm := ui.NewForm()
+	playlistForm.SetPadded(true)
+
+	playlistGrid := ui.NewGrid()
+	playlistGrid.SetPadded(true)
+
+	playlistEntry := ui.NewEntry()
+
+	playlistGrid.Append(playlistEntry, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	playlistButton := ui.NewButton("Create from the shown tracks")
+	playlistButton.OnClicked(func(b *ui.Button) {
+		playlistName := strings.TrimSpace(playlistEntry.Text())
+
+		if playlistName == "" {
+			return
+		}
+
+		if existingName, _ := findPlaylist(playlistName); existingName != "" {
+			logToEntry("Playlist \"%s\" already exists", existingName)
+
+			return
+		}
+
+		playlist := &Playlist{}
+
+		for _, item := range getExportTracks(true) {
+			playlist.Tracks = append(playlist.Tracks, item.Label())
+		}
+
+		g_appSettings.Playlists[playlistName] = playlist
+
+		logToEntry("Created playlist \"%s\" with %d tracks", playlistName, len(playlist.Tracks))
+
+		refreshPlaylists()
+
+		go trySaveSettings()
+	})
+
+	playlistGrid.Append(playlistButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	playlistForm.Append("New playlist :", playlistGrid, false)
+
+	g_playlistsModel = ui.NewTableModel(&PlaylistsTableModel{})
+	playlistsTable := ui.NewTable(&ui.TableParams{
+		Model:                         g_playlistsModel,
+		RowBackgroundColorModelColumn: 4,
+	})
+
+	playlistsTable.AppendTextColumn("Name", 0, ui.TableModelColumnNeverEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	playlistsTable.AppendTextColumn("Track IDs", 1, ui.TableModelColumnAlwaysEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	playlistsTable.AppendCheckboxColumn("Shuffle", 2, ui.TableModelColumnAlwaysEditable)
+	playlistsTable.AppendButtonColumn("Remove", 3, ui.TableModelColumnAlwaysEditable)
+
+	refreshPlaylists()
+
+	vContainer.Append(playlistForm, false)
+	vContainer.Append(playlistsTable, true)
+	vContainer.Append(ui.NewLabel("New playlists contain the tracks shown by the search and filter of the audio tab."), false)
+	vContainer.Append(ui.NewLabel("Track IDs are separated by spaces and played in order, unless shuffled."), false)
+
+	return vContainer
+}
+
+func (mh *PlaylistsTableModel) ColumnTypes(m *ui.TableModel) []ui.TableValue {
+	return []ui.TableValue{
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableInt(0),
+		ui.TableString(""),
+		ui.TableColor{},
+	}
+}
+
+func (mh *PlaylistsTableModel) NumRows(m *ui.TableModel) int {
+	if len(g_playlistNames) == 0 {
+		return 0
+	}
+
+	return len(g_playlistNames) - 1
+}
+
+func (mh *PlaylistsTableModel) CellValue(m *ui.TableModel, row, column int) ui.TableValue {
+	if column == 4 {
+		if row%2 == 0 {
+			return ui.TableColor{R: 0, G: 0, B: 0, A: 0.05}
+		}
+
+		return nil
+	}
+
+	if len(g_playlistNames) == 0 {
+		if column == 2 {
+			return ui.TableInt(0)
+		}
+
+		return ui.TableString("")
+	}
+
+	playlist := g_appSettings.Playlists[g_playlistNames[row]]
+
+	switch column {
+	case 0:
+		return ui.TableString(g_playlistNames[row])
+	case 1:
+		return ui.TableString(strings.Join(playlist.Tracks, " "))
+	case 2:
+		if playlist.Shuffle {
+			return ui.TableInt(1)
+		}
+
+		return ui.TableInt(0)
+	case 3:
+		return ui.TableString("Remove")
+	}
+
+	return nil
+}
+
+func (mh *PlaylistsTableModel) SetCellValue(m *ui.TableModel, row, column int, value ui.TableValue) {
+	if len(g_playlistNames) == 0 {
+		return
+	}
+
+	playlistName := g_playlistNames[row]
+	playlist := g_appSettings.Playlists[playlistName]
+
+	if value == nil {
+		switch column {
+		case 3:
+			delete(g_appSettings.Playlists, playlistName)
+
+			refreshPlaylists()
+
+			go trySaveSettings()
+		}
+
+		return
+	}
+
+	switch column {
+	case 1:
+		playlist.Tracks = strings.Fields(string(value.(ui.TableString)))
+	case 2:
+		playlist.Shuffle = value.(ui.TableInt) == 1
+	}
+
+	go trySaveSettings()
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type QueryNode interface {
+	Match(track *AudioTrack) bool
+}
+
+type QueryAnd struct {
+	Left  QueryNode
+	Right QueryNode
+}
+
+type QueryOr struct {
+	Left  QueryNode
+	Right QueryNode
+}
+
+type QueryNot struct {
+	Node QueryNode
+}
+
+type QueryTerm struct {
+	Field     string
+	Value     string
+	Predicate func(track *AudioTrack) bool
+}
+
+type QueryToken struct {
+	Text   string
+	Quoted bool
+}
+
+type QueryParser struct {
+	Tokens   []QueryToken
+	Position int
+}
+
+const queryHelpMessage = `name: - searches within the "Name" column, tolerating typos
+id: - compares the "ID" column, e.g. id:>100
+bind: - searches within the "Binding" column, which shows the active bank
+ext: - matches the "Extension" column, e.g. ext:.ogg
+bound: - yes or no, whether the track is bound to a key in the active bank
+vol: - compares the "Volume (%)" column, e.g. vol:>80
+dir: - searches within the folder, relative to the library directory
+root: - matches the ID prefix or the path of the library directory
+cat: - matches a category, e.g. cat:memes
+playlist: - matches the tracks of a playlist
+duration: - compares the length, e.g. duration:<5s
+played: - compares how many times the track was played
+title: - searches within the "Title" column
+artist: - searches within the "Artist" column
+comment: - searches within the "Comment" column
+channels: - compares the "Channels" column
+rate: - compares the "Sample rate" column
+
+Words without a prefix search by name.
+Terms can be combined using AND, OR, NOT (or -) and parentheses, e.g.
+name:horn ext:.ogg OR (bound:yes -dir:memes)
+Use quotes for values containing spaces, e.g. name:"air horn"`
+
+var queryFieldsMap = map[string]func(string) (func(*AudioTrack) bool, error){
+	"name":     queryName,
+	"id":       queryID,
+	"bind":     queryBind,
+	"ext":      queryExtension,
+	"bound":    queryBound,
+	"vol":      queryVolume,
+	"dir":      queryDirectory,
+	"root":     queryRoot,
+	"cat":      queryCategory,
+	"playlist": queryPlaylist,
+	"duration": queryDuration,
+	"played":   queryPlayed,
+	"title":    queryTitle,
+	"artist":   queryArtist,
+	"comment":  queryComment,
+	"channels": queryChannels,
+	"rate":     querySampleRate,
+}
+
+var queryOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+func (node *QueryAnd) Match(track *AudioTrack) bool {
+	return node.Left.Match(track) && node.Right.Match(track)
+}
+
+func (node *QueryOr) Match(track *AudioTrack) bool {
+	return node.Left.Match(track) || node.Right.Match(track)
+}
+
+func (node *QueryNot) Match(track *AudioTrack) bool {
+	return !node.Node.Match(track)
+}
+
+func (node *QueryTerm) Match(track *AudioTrack) bool {
+	return node.Predicate(track)
+}
+
+func ParseQuery(query string) (QueryNode, error) {
+	tokens, tokenError := tokenizeQuery(query)
+
+	if tokenError != nil {
+		return nil, tokenError
+	}
+
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	parser := &QueryParser{tokens, 0}
+	rootNode, parseError := parser.ParseOr()
+
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	if parser.Position < len(parser.Tokens) {
+		return nil, fmt.Errorf("unexpected \"%s\"", parser.Tokens[parser.Position].Text)
+	}
+
+	return rootNode, nil
+}
+
+func tokenizeQuery(query string) ([]QueryToken, error) {
+	var tokens []QueryToken
+	var builder strings.Builder
+
+	inQuotes := false
+	wasQuoted := false
+
+	flushToken := func() {
+		if builder.Len() == 0 && !wasQuoted {
+			return
+		}
+
+		tokens = append(tokens, QueryToken{builder.String(), wasQuoted})
+		builder.Reset()
+		wasQuoted = false
+	}
+
+	for _, character := range query {
+		switch {
+		case character == '"':
+			inQuotes = !inQuotes
+			wasQuoted = true
+		case inQuotes:
+			builder.WriteRune(character)
+		case unicode.IsSpace(character):
+			flushToken()
+		case character == '(' || character == ')':
+			flushToken()
+			tokens = append(tokens, QueryToken{string(character), false})
+		default:
+			builder.WriteRune(character)
+		}
+	}
+
+	if inQuotes {
+		return nil, errors.New("missing closing quote")
+	}
+
+	flushToken()
+
+	return tokens, nil
+}
+
+func (parser *QueryParser) Peek() *QueryToken {
+	if parser.Position >= len(parser.Tokens) {
+		return nil
+	}
+
+	return &parser.Tokens[parser.Position]
+}
+
+func (parser *QueryParser) ParseOr() (QueryNode, error) {
+	leftNode, parseError := parser.ParseAnd()
+
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	for {
+		token := parser.Peek()
+
+		if token == nil || token.Quoted || (token.Text != "OR" && token.Text != "|") {
+			return leftNode, nil
+		}
+
+		parser.Position++
+
+		rightNode, parseError := parser.ParseAnd()
+
+		if parseError != nil {
+			return nil, parseError
+		}
+
+		leftNode = &QueryOr{leftNode, rightNode}
+	}
+}
+
+func (parser *QueryParser) ParseAnd() (QueryNode, error) {
+	leftNode, parseError := parser.ParseUnary()
+
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	for {
+		token := parser.Peek()
+
+		if token == nil || (!token.Quoted && (token.Text == "OR" || token.Text == "|" || token.Text == ")")) {
+			return leftNode, nil
+		}
+
+		if !token.Quoted && (token.Text == "AND" || token.Text == "&") {
+			parser.Position++
+		}
+
+		rightNode, parseError := parser.ParseUnary()
+
+		if parseError != nil {
+			return nil, parseError
+		}
+
+		leftNode = &QueryAnd{leftNode, rightNode}
+	}
+}
+
+func (parser *QueryParser) ParseUnary() (QueryNode, error) {
+	token := parser.Peek()
+
+	if token == nil {
+		return nil, errors.New("unexpected end of query")
+	}
+
+	parser.Position++
+
+	if token.Quoted {
+		return parseQueryTerm(token.Text, true)
+	}
+
+	switch token.Text {
+	case "NOT", "-", "!":
+		node, parseError := parser.ParseUnary()
+
+		if parseError != nil {
+			return nil, parseError
+		}
+
+		return &QueryNot{node}, nil
+	case "(":
+		node, parseError := parser.ParseOr()
+
+		if parseError != nil {
+			return nil, parseError
+		}
+
+		if closingToken := parser.Peek(); closingToken == nil || closingToken.Quoted || closingToken.Text != ")" {
+			return nil, errors.New("missing closing parenthesis")
+		}
+
+		parser.Position++
+
+		return node, nil
+	case ")", "AND", "&", "OR", "|":
+		return nil, fmt.Errorf("unexpected \"%s\"", token.Text)
+	}
+
+	if strings.HasPrefix(token.Text, "-") || strings.HasPrefix(token.Text, "!") {
+		node, parseError := parseQueryTerm(token.Text[1:], false)
+
+		if parseError != nil {
+			return nil, parseError
+		}
+
+		return &QueryNot{node}, nil
+	}
+
+	return parseQueryTerm(token.Text, false)
+}
+
+func parseQueryTerm(text string, quoted bool) (QueryNode, error) {
+	field, value, hasField := strings.Cut(text, ":")
+	field = strings.ToLower(field)
+	makePredicate, exists := queryFieldsMap[field]
+
+	if hasField && !exists && !quoted {
+		return nil, fmt.Errorf("unknown field \"%s\"", field)
+	}
+
+	if !hasField || !exists {
+		field, value = "name", text
+		makePredicate = queryName
+	}
+
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return nil, fmt.Errorf("missing value for \"%s\"", field)
+	}
+
+	predicate, predicateError := makePredicate(value)
+
+	if predicateError != nil {
+		return nil, fmt.Errorf("%s: %s", field, predicateError.Error())
+	}
+
+	return &QueryTerm{field, value, predicate}, nil
+}
+
+func splitComparison(value string) (string, string) {
+	for _, operator := range queryOperators {
+		if strings.HasPrefix(value, operator) {
+			return operator, strings.TrimSpace(value[len(operator):])
+		}
+	}
+
+	return "=", value
+}
+
+func compareValues(operator string, left float64, right float64) bool {
+	switch operator {
+	case ">=":
+		return left >= right
+	case "<=":
+		return left <= right
+	case "!=":
+		return left != right
+	case ">":
+		return left > right
+	case "<":
+		return left < right
+	}
+
+	return left == right
+}
+
+func makeNumberPredicate(value string, getValue func(*AudioTrack) float64) (func(*AudioTrack) bool, error) {
+	operator, number := splitComparison(value)
+	parsedNumber, parseError := strconv.ParseFloat(number, 64)
+
+	if parseError != nil {
+		return nil, fmt.Errorf("\"%s\" is not a number", number)
+	}
+
+	return func(track *AudioTrack) bool {
+		return compareValues(operator, getValue(track), parsedNumber)
+	}, nil
+}
+
+func queryName(value string) (func(*AudioTrack) bool, error) {
+	normalized := normalizeName(value)
+	queryTokens := strings.Fields(normalized)
+
+	if normalized == "" {
+		return nil, errors.New("the name must contain letters or digits")
+	}
+
+	return func(track *AudioTrack) bool {
+		return scoreEntry(normalized, queryTokens, getIndexEntry(track)) >= float64(g_appSettings.FuzzyThreshold)
+	}, nil
+}
+
+func queryID(value string) (func(*AudioTrack) bool, error) {
+	return makeNumberPredicate(value, func(track *AudioTrack) float64 { return float64(track.ID) })
+}
+
+func queryBind(value string) (func(*AudioTrack) bool, error) {
+	upperValue := strings.ToUpper(value)
+
+	return func(track *AudioTrack) bool {
+		return track.ActiveBinding() != 0 && strings.Contains(formatKey(track.Binding), upperValue)
+	}, nil
+}
+
+func queryExtension(value string) (func(*AudioTrack) bool, error) {
+	if !strings.HasPrefix(value, ".") {
+		value = "." + value
+	}
+
+	return func(track *AudioTrack) bool {
+		return strings.EqualFold(track.Extension, value)
+	}, nil
+}
+
+func queryBound(value string) (func(*AudioTrack) bool, error) {
+	var isBound bool
+
+	switch strings.ToLower(value) {
+	case "yes", "true", "1":
+		isBound = true
+	case "no", "false", "0":
+		isBound = false
+	default:
+		return nil, errors.New("expected yes or no")
+	}
+
+	return func(track *AudioTrack) bool {
+		return (track.ActiveBinding() != 0) == isBound
+	}, nil
+}
+
+func queryVolume(value string) (func(*AudioTrack) bool, error) {
+	return makeNumberPredicate(value, func(track *AudioTrack) float64 { return float64(track.Volume) })
+}
+
+func queryDirectory(value string) (func(*AudioTrack) bool, error) {
+	lowerValue := strings.ToLower(filepath.ToSlash(value))
+
+	return func(track *AudioTrack) bool {
+		trackDirectory := filepath.Dir(track.Path)
+
+		if track.Root != nil {
+			if relativePath, relError := filepath.Rel(track.Root.FullPath(), trackDirectory); relError == nil {
+				trackDirectory = relativePath
+			}
+		}
+
+		return strings.Contains(strings.ToLower(filepath.ToSlash(trackDirectory)), lowerValue)
+	}, nil
+}
+
+func queryRoot(value string) (func(*AudioTrack) bool, error) {
+	lowerValue := strings.ToLower(filepath.ToSlash(value))
+
+	return func(track *AudioTrack) bool {
+		if track.Root == nil {
+			return false
+		}
+
+		if track.Root.Prefix != "" && strings.EqualFold(track.Root.Prefix, value) {
+			return true
+		}
+
+		return strings.Contains(strings.ToLower(track.Root.Path), lowerValue)
+	}, nil
+}
+
+func queryCategory(value string) (func(*AudioTrack) bool, error) {
+	return func(track *AudioTrack) bool {
+		return track.HasCategory(value)
+	}, nil
+}
+
+func queryPlaylist(value string) (func(*AudioTrack) bool, error) {
+	_, playlist := findPlaylist(value)
+
+	if playlist == nil {
+		return nil, fmt.Errorf("no playlist named \"%s\"", value)
+	}
+
+	return playlist.Contains, nil
+}
+
+func queryDuration(value string) (func(*AudioTrack) bool, error) {
+	operator, durationText := splitComparison(value)
+
+	var parsedDuration time.Duration
+
+	if seconds, parseError := strconv.ParseFloat(durationText, 64); parseError == nil {
+		parsedDuration = time.Duration(seconds * float64(time.Second))
+	} else {
+		var durationError error
+		parsedDuration, durationError = time.ParseDuration(durationText)
+
+		if durationError != nil {
+			return nil, fmt.Errorf("\"%s\" is not a duration", durationText)
+		}
+	}
+
+	return func(track *AudioTrack) bool {
+		// Tracks which were not indexed nor opened have an unknown length
+
+		if track.Duration == 0 {
+			return false
+		}
+
+		return compareValues(operator, float64(track.Duration), float64(parsedDuration))
+	}, nil
+}
+
+func queryPlayed(value string) (func(*AudioTrack) bool, error) {
+	return makeNumberPredicate(value, func(track *AudioTrack) float64 { return float64(track.Plays) })
+}
+
+func makeTagPredicate(value string, getTag func(*TrackMetadata) string) (func(*AudioTrack) bool, error) {
+	lowerValue := strings.ToLower(value)
+
+	return func(track *AudioTrack) bool {
+		return track.Metadata != nil && strings.Contains(strings.ToLower(getTag(track.Metadata)), lowerValue)
+	}, nil
+}
+
+func makeMetadataPredicate(value string, getValue func(*TrackMetadata) float64) (func(*AudioTrack) bool, error) {
+	numberPredicate, parseError := makeNumberPredicate(value, func(track *AudioTrack) float64 { return getValue(track.Metadata) })
+
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	return func(track *AudioTrack) bool {
+		return track.Metadata != nil && numberPredicate(track)
+	}, nil
+}
+
+func queryTitle(value string) (func(*AudioTrack) bool, error) {
+	return makeTagPredicate(value, func(metadata *TrackMetadata) string { return metadata.Title })
+}
+
+func queryArtist(value string) (func(*AudioTrack) bool, error) {
+	return makeTagPredicate(value, func(metadata *TrackMetadata) string { return metadata.Artist })
+}
+
+func queryComment(value string) (func(*AudioTrack) bool, error) {
+	return makeTagPredicate(value, func(metadata *TrackMetadata) string { return metadata.Comment })
+}
+
+func queryChannels(value string) (func(*AudioTrack) bool, error) {
+	return makeMetadataPredicate(value, func(metadata *TrackMetadata) float64 { return float64(metadata.Channels) })
+}
+
+func querySampleRate(value string) (func(*AudioTrack) bool, error) {
+	return makeMetadataPredicate(value, func(metadata *TrackMetadata) float64 { return float64(metadata.SampleRate) })
+}
+
+func filterTracks(query QueryNode) {
+	if term, isTerm := query.(*QueryTerm); isTerm && term.Field == "name" {
+		searchName(term.Value)
+
+		return
+	}
+
+	for _, item := range g_tracksList {
+		if !query.Match(item) {
+			continue
+		}
+
+		item.Row = len(g_filteredList)
+		g_filteredList = append(g_filteredList, item.Index)
+	}
+}
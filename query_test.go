@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moutend/go-hook/pkg/types"
+)
+
+// formatQueryNode writes the tree with explicit parentheses, so precedence shows in the expected strings.
+
+func formatQueryNode(node QueryNode) string {
+	switch node := node.(type) {
+	case *QueryAnd:
+		return "(" + formatQueryNode(node.Left) + " AND " + formatQueryNode(node.Right) + ")"
+	case *QueryOr:
+		return "(" + formatQueryNode(node.Left) + " OR " + formatQueryNode(node.Right) + ")"
+	case *QueryNot:
+		return "NOT " + formatQueryNode(node.Node)
+	case *QueryTerm:
+		return node.Field + ":" + node.Value
+	}
+
+	return fmt.Sprintf("%T", node)
+}
+
+func TestParseQueryStructure(t *testing.T) {
+	testCases := []struct {
+		Query string
+		Tree  string
+	}{
+		{"horn", "name:horn"},
+		{"horn siren", "(name:horn AND name:siren)"},
+		{"horn AND siren", "(name:horn AND name:siren)"},
+		{"horn & siren", "(name:horn AND name:siren)"},
+		{"horn OR siren fanfare", "(name:horn OR (name:siren AND name:fanfare))"},
+		{"horn siren | fanfare", "((name:horn AND name:siren) OR name:fanfare)"},
+		{"(horn OR siren) fanfare", "((name:horn OR name:siren) AND name:fanfare)"},
+		{"horn OR (siren fanfare)", "(name:horn OR (name:siren AND name:fanfare))"},
+		{"-dir:memes", "NOT dir:memes"},
+		{"!ext:.ogg", "NOT ext:.ogg"},
+		{"NOT horn", "NOT name:horn"},
+		{"- horn", "NOT name:horn"},
+		{"NOT (horn OR siren)", "NOT (name:horn OR name:siren)"},
+		{"NOT NOT horn", "NOT NOT name:horn"},
+		{`name:"air horn"`, "name:air horn"},
+		{`"air horn" OR siren`, "(name:air horn OR name:siren)"},
+		{`"OR"`, "name:OR"},
+		{`"foo:bar"`, "name:foo:bar"},
+		{"NAME:horn", "name:horn"},
+		{"vol:>80", "vol:>80"},
+		{"name:horn ext:.ogg bound:yes vol:>80 dir:memes duration:<5s played:>10",
+			"((((((name:horn AND ext:.ogg) AND bound:yes) AND vol:>80) AND dir:memes) AND duration:<5s) AND played:>10)"},
+	}
+
+	for _, testCase := range testCases {
+		rootNode, parseError := ParseQuery(testCase.Query)
+
+		if parseError != nil {
+			t.Errorf("ParseQuery(%q) : %v", testCase.Query, parseError)
+
+			continue
+		}
+
+		if tree := formatQueryNode(rootNode); tree != testCase.Tree {
+			t.Errorf("ParseQuery(%q) = %s, want %s", testCase.Query, tree, testCase.Tree)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	testCases := []struct {
+		Query string
+		Error string
+	}{
+		{"foo:bar", `unknown field "foo"`},
+		{"name:", `missing value for "name"`},
+		{`name:""`, `missing value for "name"`},
+		{"(horn OR siren", "missing closing parenthesis"},
+		{"horn)", `unexpected ")"`},
+		{`"air horn`, "missing closing quote"},
+		{"horn OR", "unexpected end of query"},
+		{"NOT", "unexpected end of query"},
+		{"AND horn", `unexpected "AND"`},
+		{"vol:>loud", `vol: "loud" is not a number`},
+		{"bound:maybe", "bound: expected yes or no"},
+		{"duration:<soon", `duration: "soon" is not a duration`},
+	}
+
+	for _, testCase := range testCases {
+		_, parseError := ParseQuery(testCase.Query)
+
+		if parseError == nil || parseError.Error() != testCase.Error {
+			t.Errorf("ParseQuery(%q) returned error %v, want %q", testCase.Query, parseError, testCase.Error)
+		}
+	}
+
+	if rootNode, parseError := ParseQuery("   "); rootNode != nil || parseError != nil {
+		t.Errorf("ParseQuery of a blank query returned %v, %v", rootNode, parseError)
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	previousThreshold, previousBank := g_appSettings.FuzzyThreshold, g_appSettings.ActiveBank
+
+	defer func() {
+		g_appSettings.FuzzyThreshold, g_appSettings.ActiveBank = previousThreshold, previousBank
+	}()
+
+	g_appSettings.FuzzyThreshold = defaultFuzzyThreshold
+	g_appSettings.ActiveBank = 0
+
+	makeTrack := func(change func(*AudioTrack)) *AudioTrack {
+		track := &AudioTrack{
+			ID:        7,
+			Name:      "Air horn",
+			Extension: ".ogg",
+			Path:      filepath.Join("sounds", "memes", "Air horn.ogg"),
+			Volume:    90,
+			Binding:   types.VK_F1,
+			Duration:  3 * time.Second,
+			Plays:     12,
+		}
+
+		if change != nil {
+			change(track)
+		}
+
+		return track
+	}
+
+	exampleQuery, parseError := ParseQuery("name:horn ext:.ogg bound:yes vol:>80 dir:memes duration:<5s played:>10")
+
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+
+	if !exampleQuery.Match(makeTrack(nil)) {
+		t.Fatal("The example query does not match a track meeting every term")
+	}
+
+	failingTracks := map[string]func(*AudioTrack){
+		"name":     func(track *AudioTrack) { track.Name = "Sad trombone" },
+		"ext":      func(track *AudioTrack) { track.Extension = ".wav" },
+		"bound":    func(track *AudioTrack) { track.Binding = 0 },
+		"bank":     func(track *AudioTrack) { track.Bank = 1 },
+		"vol":      func(track *AudioTrack) { track.Volume = 80 },
+		"dir":      func(track *AudioTrack) { track.Path = filepath.Join("sounds", "sfx", "Air horn.ogg") },
+		"duration": func(track *AudioTrack) { track.Duration = 5 * time.Second },
+		"unknown":  func(track *AudioTrack) { track.Duration = 0 },
+		"played":   func(track *AudioTrack) { track.Plays = 10 },
+	}
+
+	for name, change := range failingTracks {
+		if exampleQuery.Match(makeTrack(change)) {
+			t.Errorf("The example query matches a track failing its %s term", name)
+		}
+	}
+
+	testCases := []struct {
+		Query string
+		Match bool
+	}{
+		{"horn OR trombone", true},
+		{"trombone OR siren", false},
+		{"horn -ext:.ogg", false},
+		{"horn !ext:wav", true},
+		{"NOT (ext:.wav OR bound:no)", true},
+		{"id:>=7 id:<8", true},
+		{"id:!=7", false},
+		{"bind:f1", true},
+		{`name:"air horn" vol:90`, true},
+	}
+
+	track := makeTrack(nil)
+
+	for _, testCase := range testCases {
+		rootNode, parseError := ParseQuery(testCase.Query)
+
+		if parseError != nil {
+			t.Errorf("ParseQuery(%q) : %v", testCase.Query, parseError)
+
+			continue
+		}
+
+		if rootNode.Match(track) != testCase.Match {
+			t.Errorf("%q matched %v, want %v", testCase.Query, !testCase.Match, testCase.Match)
+		}
+	}
+}
+
+func TestTokenizeQuery(t *testing.T) {
+	tokens, tokenError := tokenizeQuery(`(name:"air  horn" OR "")-dir:x`)
+
+	if tokenError != nil {
+		t.Fatal(tokenError)
+	}
+
+	var parts []string
+
+	for _, item := range tokens {
+		if item.Quoted {
+			parts = append(parts, "["+item.Text+"]")
+		} else {
+			parts = append(parts, item.Text)
+		}
+	}
+
+	if joined := strings.Join(parts, " "); joined != "( [name:air  horn] OR [] ) -dir:x" {
+		t.Fatalf("tokenizeQuery returned %s", joined)
+	}
+}
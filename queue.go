@@ -0,0 +1,432 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"waveboard/fixes/ui"
+)
+
+// AudioQueue holds the tracks waiting to be played, first to last.
+// Tracks with an ID of -1 were removed from the library and are freed by releaseTrack once they leave the queue.
+
+type AudioQueue struct {
+	Entries    []*QueueEntry
+	RoundRobin bool
+	Random     *rand.Rand
+	Mutex      sync.Mutex
+}
+
+// Tracks queued from the user interface have no requester.
+
+type QueueEntry struct {
+	Track     *AudioTrack
+	Requester string
+}
+
+var g_audioQueue = NewAudioQueue(time.Now().UnixNano())
+
+var queuePositionError = errors.New("position is outside of the queue")
+
+func NewAudioQueue(seed int64) *AudioQueue {
+	return &AudioQueue{
+		Entries: nil,
+		Random:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (queue *AudioQueue) Len() int {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	return len(queue.Entries)
+}
+
+func (queue *AudioQueue) Get(index int) *QueueEntry {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	if index < 0 || index >= len(queue.Entries) {
+		return nil
+	}
+
+	return queue.Entries[index]
+}
+
+func (queue *AudioQueue) IsFull(limit int) bool {
+	return limit != 0 && queue.Len() >= limit
+}
+
+func (queue *AudioQueue) CountRequester(requester string) int {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	return queue.countRequester(requester, len(queue.Entries))
+}
+
+// HasReachedQuota never limits the user interface.
+
+func (queue *AudioQueue) HasReachedQuota(requester string, limit int) bool {
+	return limit != 0 && requester != "" && queue.CountRequester(requester) >= limit
+}
+
+func (queue *AudioQueue) countRequester(requester string, end int) int {
+	count := 0
+
+	for _, item := range queue.Entries[:end] {
+		if strings.EqualFold(item.Requester, requester) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Push returns the 1-based position of the added track.
+// In round-robin mode, the track goes before the first entry of a later round,
+// a round being the number of earlier entries of the same requester.
+
+func (queue *AudioQueue) Push(track *AudioTrack, requester string) int {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	entry := &QueueEntry{track, requester}
+
+	if !queue.RoundRobin {
+		queue.Entries = append(queue.Entries, entry)
+
+		return len(queue.Entries)
+	}
+
+	entryRound := 0
+	firstIndex := 0
+
+	// Never overtake an earlier entry of the same requester
+
+	for index, item := range queue.Entries {
+		if strings.EqualFold(item.Requester, requester) {
+			entryRound++
+			firstIndex = index + 1
+		}
+	}
+
+	position := len(queue.Entries)
+
+	for index := firstIndex; index < len(queue.Entries); index++ {
+		if queue.countRequester(queue.Entries[index].Requester, index) > entryRound {
+			position = index
+
+			break
+		}
+	}
+
+	queue.Entries = append(queue.Entries, nil)
+	copy(queue.Entries[position+1:], queue.Entries[position:])
+	queue.Entries[position] = entry
+
+	return position + 1
+}
+
+func (queue *AudioQueue) PopFront() *QueueEntry {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	if len(queue.Entries) == 0 {
+		return nil
+	}
+
+	entry := queue.Entries[0]
+
+	queue.Entries[0] = nil
+	queue.Entries = queue.Entries[1:]
+
+	return entry
+}
+
+func (queue *AudioQueue) Remove(index int) (*QueueEntry, error) {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	if index < 0 || index >= len(queue.Entries) {
+		return nil, queuePositionError
+	}
+
+	entry := queue.Entries[index]
+
+	copy(queue.Entries[index:], queue.Entries[index+1:])
+	queue.Entries[len(queue.Entries)-1] = nil
+	queue.Entries = queue.Entries[:len(queue.Entries)-1]
+
+	return entry, nil
+}
+
+// Move shifts the entries between both positions to make room and returns the moved entry.
+
+func (queue *AudioQueue) Move(from int, to int) (*QueueEntry, error) {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	if from < 0 || from >= len(queue.Entries) || to < 0 || to >= len(queue.Entries) {
+		return nil, queuePositionError
+	}
+
+	entry := queue.Entries[from]
+
+	if from < to {
+		copy(queue.Entries[from:to], queue.Entries[from+1:to+1])
+	} else {
+		copy(queue.Entries[to+1:from+1], queue.Entries[to:from])
+	}
+
+	queue.Entries[to] = entry
+
+	return entry, nil
+}
+
+func (queue *AudioQueue) MoveToFront(index int) (*QueueEntry, error) {
+	return queue.Move(index, 0)
+}
+
+func (queue *AudioQueue) Shuffle() {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	queue.Random.Shuffle(len(queue.Entries), func(i, j int) {
+		queue.Entries[i], queue.Entries[j] = queue.Entries[j], queue.Entries[i]
+	})
+}
+
+// Deduplicate keeps the first entry of every track and returns the removed ones.
+// Removed library tracks are matched by path, as a rescan creates new objects for the same file.
+
+func (queue *AudioQueue) Deduplicate() []*QueueEntry {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	var removedEntries []*QueueEntry
+
+	seenTracks := make(map[*AudioTrack]bool, len(queue.Entries))
+	seenPaths := make(map[string]bool, len(queue.Entries))
+	keptEntries := queue.Entries[:0]
+
+	for _, item := range queue.Entries {
+		if seenTracks[item.Track] || seenPaths[item.Track.Path] {
+			removedEntries = append(removedEntries, item)
+
+			continue
+		}
+
+		seenTracks[item.Track] = true
+		seenPaths[item.Track.Path] = true
+		keptEntries = append(keptEntries, item)
+	}
+
+	for index := len(keptEntries); index < len(queue.Entries); index++ {
+		queue.Entries[index] = nil
+	}
+
+	queue.Entries = keptEntries
+
+	return removedEntries
+}
+
+// Restore replaces the entries without applying round-robin, as they are already ordered.
+
+func (queue *AudioQueue) Restore(entries []*QueueEntry) {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	queue.Entries = entries
+}
+
+func (queue *AudioQueue) Clear() []*QueueEntry {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	removedEntries := queue.Entries
+	queue.Entries = nil
+
+	return removedEntries
+}
+
+func (queue *AudioQueue) Contains(track *AudioTrack) bool {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	for _, item := range queue.Entries {
+		if item.Track == track {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ForEach must not call the other methods of the queue.
+
+func (queue *AudioQueue) ForEach(callback func(index int, entry *QueueEntry)) {
+	queue.Mutex.Lock()
+	defer queue.Mutex.Unlock()
+
+	for index, item := range queue.Entries {
+		callback(index, item)
+	}
+}
+
+// releaseTrack frees a track which left the queue, if nothing else uses it.
+
+func releaseTrack(track *AudioTrack) {
+	if track.ID != -1 || track == g_currentTrack || g_audioQueue.Contains(track) {
+		return
+	}
+
+	track.ClearTrackSafe()
+}
+
+func releaseEntries(entries []*QueueEntry) {
+	for _, item := range entries {
+		releaseTrack(item.Track)
+	}
+}
+
+func refreshQueue() {
+	ui.QueueMain(func() { g_queueModel.RowInserted(0) })
+
+	trySaveQueue()
+}
+
+// playQueued plays a queued track right away, interrupting the current one.
+
+func playQueued(index int) (*AudioTrack, error) {
+	entry, removeError := g_audioQueue.Remove(index)
+
+	if removeError != nil {
+		return nil, removeError
+	}
+
+	refreshQueue()
+
+	return entry.Track, playSoundFrom(entry.Track, g_selectedDevice, 0, entry.Requester)
+}
+
+// parseQueuePosition turns a 1-based position typed in chat into an index.
+
+func parseQueuePosition(text string) (int, error) {
+	position, parseError := strconv.ParseUint(strings.TrimSpace(text), 10, 32)
+
+	if parseError != nil || position == 0 {
+		return -1, fmt.Errorf("\"%s\" is not a queue position", text)
+	}
+
+	return int(position) - 1, nil
+}
+
+func removeCommand(playerName string, arg string) {
+	index, positionError := parseQueuePosition(arg)
+
+	if positionError != nil {
+		sendReply("remove.invalid", "{user}", playerName, "{error}", positionError.Error())
+
+		return
+	}
+
+	entry, removeError := g_audioQueue.Remove(index)
+
+	if removeError != nil {
+		sendReply("remove.invalid", "{user}", playerName, "{error}", removeError.Error())
+
+		return
+	}
+
+	sendReply("remove.removed", "{user}", playerName, "{id}", entry.Track.Label(), "{name}", entry.Track.Name, "{position}", strconv.Itoa(index+1))
+
+	releaseTrack(entry.Track)
+	refreshQueue()
+}
+
+func moveCommand(playerName string, arg string) {
+	positions := strings.Fields(arg)
+
+	if len(positions) == 1 {
+		positions = append(positions, "1")
+	}
+
+	if len(positions) != 2 {
+		sendReply("move.invalid", "{user}", playerName, "{error}", "expected the current and the new position")
+
+		return
+	}
+
+	from, fromError := parseQueuePosition(positions[0])
+	to, toError := parseQueuePosition(positions[1])
+
+	if positionError := errors.Join(fromError, toError); positionError != nil {
+		sendReply("move.invalid", "{user}", playerName, "{error}", positionError.Error())
+
+		return
+	}
+
+	entry, moveError := g_audioQueue.Move(from, to)
+
+	if moveError != nil {
+		sendReply("move.invalid", "{user}", playerName, "{error}", moveError.Error())
+
+		return
+	}
+
+	track := entry.Track
+
+	sendReply("move.moved", "{user}", playerName, "{id}", track.Label(), "{name}", track.Name, "{position}", strconv.Itoa(to+1))
+
+	refreshQueue()
+}
+
+func shuffleCommand(playerName string, arg string) {
+	g_audioQueue.Shuffle()
+
+	sendReply("shuffle.shuffled", "{user}", playerName, "{count}", strconv.Itoa(g_audioQueue.Len()))
+
+	refreshQueue()
+}
+
+func dedupeCommand(playerName string, arg string) {
+	removedEntries := g_audioQueue.Deduplicate()
+
+	sendReply("dedupe.removed", "{user}", playerName, "{count}", strconv.Itoa(len(removedEntries)))
+
+	releaseEntries(removedEntries)
+	refreshQueue()
+}
+
+func playNowCommand(playerName string, arg string) {
+	index, positionError := parseQueuePosition(arg)
+
+	if positionError != nil {
+		sendReply("playnow.invalid", "{user}", playerName, "{error}", positionError.Error())
+
+		return
+	}
+
+	track, playError := playQueued(index)
+
+	if track == nil {
+		sendReply("playnow.invalid", "{user}", playerName, "{error}", playError.Error())
+
+		return
+	}
+
+	if playError != nil {
+		ui.QueueMain(func() { logToEntry(playError.Error()) })
+
+		sendReply("playnow.failed", "{user}", playerName, "{name}", track.Name, "{error}", playError.Error())
+
+		return
+	}
+
+	sendReply("playnow.playing", "{user}", playerName, "{id}", track.Label(), "{name}", track.Name)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+const queueTestSeed = 7
+
+func makeTestQueue(names ...string) (*AudioQueue, []*AudioTrack) {
+	queue := NewAudioQueue(queueTestSeed)
+	tracks := makeFuzzyTracks(names...)
+
+	for _, item := range tracks {
+		item.Path = item.Name + ".wav"
+		queue.Push(item, "")
+	}
+
+	return queue, tracks
+}
+
+func formatQueue(queue *AudioQueue) string {
+	var names []string
+
+	queue.ForEach(func(index int, entry *QueueEntry) {
+		names = append(names, entry.Track.Name)
+	})
+
+	return strings.Join(names, " ")
+}
+
+func TestQueueMove(t *testing.T) {
+	testCases := []struct {
+		From  int
+		To    int
+		Queue string
+	}{
+		{0, 4, "b c d e a"},
+		{4, 0, "e a b c d"},
+		{1, 3, "a c d b e"},
+		{3, 1, "a d b c e"},
+		{2, 2, "a b c d e"},
+	}
+
+	for _, testCase := range testCases {
+		queue, _ := makeTestQueue("a", "b", "c", "d", "e")
+
+		entry, moveError := queue.Move(testCase.From, testCase.To)
+
+		if moveError != nil {
+			t.Errorf("Move(%d, %d) : %v", testCase.From, testCase.To, moveError)
+
+			continue
+		}
+
+		if entry != queue.Get(testCase.To) {
+			t.Errorf("Move(%d, %d) returned %q, not the moved entry", testCase.From, testCase.To, entry.Track.Name)
+		}
+
+		if order := formatQueue(queue); order != testCase.Queue {
+			t.Errorf("Move(%d, %d) gave %q, want %q", testCase.From, testCase.To, order, testCase.Queue)
+		}
+	}
+
+	queue, _ := makeTestQueue("a", "b", "c")
+
+	for _, positions := range [][2]int{{-1, 0}, {0, -1}, {3, 0}, {0, 3}} {
+		if entry, moveError := queue.Move(positions[0], positions[1]); entry != nil || moveError != queuePositionError {
+			t.Errorf("Move(%d, %d) returned %v, %v, want the position error", positions[0], positions[1], entry, moveError)
+		}
+	}
+
+	if entry, moveError := queue.MoveToFront(2); moveError != nil || entry.Track.Name != "c" || formatQueue(queue) != "c a b" {
+		t.Fatalf("MoveToFront(2) gave %q, %v", formatQueue(queue), moveError)
+	}
+
+	if _, moveError := NewAudioQueue(queueTestSeed).Move(0, 0); moveError != queuePositionError {
+		t.Fatalf("Move in an empty queue returned %v", moveError)
+	}
+}
+
+func TestQueueRemove(t *testing.T) {
+	queue, tracks := makeTestQueue("a", "b", "c")
+
+	// A track removed from the library stays in the queue with an ID of -1
+
+	tracks[1].ID = -1
+
+	entry, removeError := queue.Remove(1)
+
+	if removeError != nil || entry.Track != tracks[1] {
+		t.Fatalf("Remove(1) returned %v, %v", entry, removeError)
+	}
+
+	if queue.Contains(tracks[1]) || formatQueue(queue) != "a c" {
+		t.Fatalf("Remove(1) left %q", formatQueue(queue))
+	}
+
+	for _, index := range []int{-1, 2} {
+		if _, removeError = queue.Remove(index); removeError != queuePositionError {
+			t.Errorf("Remove(%d) returned %v, want the position error", index, removeError)
+		}
+	}
+
+	if entry = queue.PopFront(); entry.Track != tracks[0] || formatQueue(queue) != "c" {
+		t.Fatalf("PopFront returned %q and left %q", entry.Track.Name, formatQueue(queue))
+	}
+}
+
+func TestQueueDeduplicate(t *testing.T) {
+	queue, tracks := makeTestQueue("a", "b", "a", "c", "b")
+
+	// The second "a" is another object for the same file, as after a rescan, and the first one left the library
+
+	tracks[0].ID = -1
+	queue.Push(tracks[3], "")
+
+	removedEntries := queue.Deduplicate()
+
+	if order := formatQueue(queue); order != "a b c" {
+		t.Fatalf("Deduplicate left %q, want \"a b c\"", order)
+	}
+
+	if queue.Get(0).Track != tracks[0] {
+		t.Fatal("Deduplicate did not keep the first entry of a file")
+	}
+
+	var removedNames []string
+
+	for _, item := range removedEntries {
+		removedNames = append(removedNames, item.Track.Name)
+	}
+
+	if strings.Join(removedNames, " ") != "a b c" || removedEntries[0].Track != tracks[2] {
+		t.Fatalf("Deduplicate removed %q, want \"a b c\"", removedNames)
+	}
+
+	if removedEntries = queue.Deduplicate(); len(removedEntries) != 0 {
+		t.Fatalf("Deduplicate of a queue without duplicates removed %d entries", len(removedEntries))
+	}
+}
+
+func TestQueueShuffle(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	firstQueue, _ := makeTestQueue(names...)
+	secondQueue, _ := makeTestQueue(names...)
+
+	firstQueue.Shuffle()
+	secondQueue.Shuffle()
+
+	firstOrder := formatQueue(firstQueue)
+
+	if firstOrder != formatQueue(secondQueue) {
+		t.Fatalf("Shuffle with the same seed gave %q and %q", firstOrder, formatQueue(secondQueue))
+	}
+
+	if firstOrder == strings.Join(names, " ") {
+		t.Fatal("Shuffle kept the order of the queue")
+	}
+
+	shuffledNames := strings.Fields(firstOrder)
+	sort.Strings(shuffledNames)
+
+	if strings.Join(shuffledNames, " ") != strings.Join(names, " ") {
+		t.Fatalf("Shuffle changed the entries to %q", firstOrder)
+	}
+}
+
+func TestQueueRoundRobin(t *testing.T) {
+	queue := NewAudioQueue(queueTestSeed)
+	queue.RoundRobin = true
+
+	tracks := makeFuzzyTracks("a1", "a2", "a3", "b1", "c1", "b2")
+	requesters := []string{"Alice", "alice", "ALICE", "Bob", "Carol", "Bob"}
+	wantPositions := []int{1, 2, 3, 2, 3, 5}
+
+	for index, item := range tracks {
+		if position := queue.Push(item, requesters[index]); position != wantPositions[index] {
+			t.Errorf("Push of %q returned position %d, want %d", item.Name, position, wantPositions[index])
+		}
+
+		if index == 4 {
+			if order := formatQueue(queue); order != "a1 b1 c1 a2 a3" {
+				t.Fatalf("Round-robin queue is %q, want \"a1 b1 c1 a2 a3\"", order)
+			}
+		}
+	}
+
+	if order := formatQueue(queue); order != "a1 b1 c1 a2 b2 a3" {
+		t.Fatalf("Round-robin queue is %q, want \"a1 b1 c1 a2 b2 a3\"", order)
+	}
+
+	// Entries from the user interface have no requester and are grouped like one
+
+	queue, _ = makeTestQueue("a", "b")
+	queue.RoundRobin = true
+
+	if position := queue.Push(tracks[0], "Alice"); position != 2 || formatQueue(queue) != "a a1 b" {
+		t.Fatalf("Push after entries without requester gave %q at %d", formatQueue(queue), position)
+	}
+}
+
+func TestQueueLimits(t *testing.T) {
+	queue := NewAudioQueue(queueTestSeed)
+	tracks := makeFuzzyTracks("a", "b", "c")
+
+	queue.Push(tracks[0], "Alice")
+	queue.Push(tracks[1], "alice")
+	queue.Push(tracks[2], "")
+
+	testCases := []struct {
+		Requester string
+		Limit     int
+		Reached   bool
+	}{
+		{"Alice", 2, true},
+		{"ALICE", 3, false},
+		{"Alice", 0, false},
+		{"Bob", 1, false},
+		{"", 1, false},
+	}
+
+	for _, testCase := range testCases {
+		if queue.HasReachedQuota(testCase.Requester, testCase.Limit) != testCase.Reached {
+			t.Errorf("HasReachedQuota(%q, %d) returned %v", testCase.Requester, testCase.Limit, !testCase.Reached)
+		}
+	}
+
+	if queue.CountRequester("alice") != 2 {
+		t.Errorf("CountRequester(\"alice\") returned %d, want 2", queue.CountRequester("alice"))
+	}
+
+	if !queue.IsFull(3) || queue.IsFull(4) || queue.IsFull(0) {
+		t.Errorf("IsFull with 3 entries returned %v, %v and %v for limits 3, 4 and 0", queue.IsFull(3), queue.IsFull(4), queue.IsFull(0))
+	}
+}
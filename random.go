@@ -0,0 +1,215 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"waveboard/fixes/ui"
+)
+
+// RandomPicker chooses weighted random tracks, skipping the most recently played ones.
+// Pass a fixed seed to get the same choices for the same history.
+
+type RandomPicker struct {
+	Random      *rand.Rand
+	History     []*AudioTrack
+	HistorySize int
+	Mutex       sync.Mutex
+}
+
+const defaultTrackWeight float32 = 1.0
+const defaultRandomHistory = 5
+
+var g_randomPicker = NewRandomPicker(time.Now().UnixNano(), defaultRandomHistory)
+
+func NewRandomPicker(seed int64, historySize int) *RandomPicker {
+	return &RandomPicker{
+		Random:      rand.New(rand.NewSource(seed)),
+		History:     nil,
+		HistorySize: historySize,
+	}
+}
+
+func (track *AudioTrack) GetWeight() float32 {
+	if track.Weight <= 0 {
+		return defaultTrackWeight
+	}
+
+	return track.Weight
+}
+
+// Pick returns nil only when there are no tracks.
+// When every track was played recently, the history is ignored instead.
+
+func (picker *RandomPicker) Pick(tracks []*AudioTrack) *AudioTrack {
+	picker.Mutex.Lock()
+	defer picker.Mutex.Unlock()
+
+	if len(tracks) == 0 {
+		return nil
+	}
+
+	recentTracks := make(map[*AudioTrack]bool, len(picker.History))
+
+	for _, item := range picker.History {
+		recentTracks[item] = true
+	}
+
+	candidates := make([]*AudioTrack, 0, len(tracks))
+
+	for _, item := range tracks {
+		if !recentTracks[item] {
+			candidates = append(candidates, item)
+		}
+	}
+
+	if len(candidates) == 0 {
+		candidates = tracks
+	}
+
+	var totalWeight float64
+
+	for _, item := range candidates {
+		totalWeight += float64(item.GetWeight())
+	}
+
+	target := picker.Random.Float64() * totalWeight
+
+	for _, item := range candidates {
+		target -= float64(item.GetWeight())
+
+		if target < 0 {
+			return item
+		}
+	}
+
+	// Rounding may leave a tiny remainder
+
+	return candidates[len(candidates)-1]
+}
+
+// Remember is called for every played track, not only for random ones.
+
+func (picker *RandomPicker) Remember(track *AudioTrack) {
+	picker.Mutex.Lock()
+	defer picker.Mutex.Unlock()
+
+	if picker.HistorySize <= 0 {
+		picker.History = nil
+
+		return
+	}
+
+	picker.History = append(picker.History, track)
+
+	if len(picker.History) > picker.HistorySize {
+		picker.History = append(picker.History[:0], picker.History[len(picker.History)-picker.HistorySize:]...)
+	}
+}
+
+func (picker *RandomPicker) SetHistorySize(historySize int) {
+	picker.Mutex.Lock()
+	defer picker.Mutex.Unlock()
+
+	picker.HistorySize = historySize
+
+	if historySize <= 0 {
+		picker.History = nil
+	} else if len(picker.History) > historySize {
+		picker.History = append(picker.History[:0], picker.History[len(picker.History)-historySize:]...)
+	}
+}
+
+func (picker *RandomPicker) Shuffle(tracks []*AudioTrack) {
+	picker.Mutex.Lock()
+	defer picker.Mutex.Unlock()
+
+	picker.Random.Shuffle(len(tracks), func(i, j int) {
+		tracks[i], tracks[j] = tracks[j], tracks[i]
+	})
+}
+
+// getRandomCandidates accepts a category name or a search query.
+
+func getRandomCandidates(filter string) ([]*AudioTrack, error) {
+	if strings.TrimSpace(filter) == "" {
+		return g_tracksList, nil
+	}
+
+	if tracks := getCategoryTracks(filter); len(tracks) != 0 {
+		return tracks, nil
+	}
+
+	query, parseError := ParseQuery(filter)
+
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	var tracks []*AudioTrack
+
+	for _, item := range g_tracksList {
+		if query.Match(item) {
+			tracks = append(tracks, item)
+		}
+	}
+
+	return tracks, nil
+}
+
+func randomCommand(playerName string, arg string) {
+	if g_audioQueue.IsFull(g_appSettings.QueueLimit) {
+		sendReply("random.queuefull", "{user}", playerName, "{limit}", strconv.Itoa(g_appSettings.QueueLimit))
+
+		return
+	}
+
+	if g_audioQueue.HasReachedQuota(playerName, g_appSettings.UserQueueLimit) {
+		sendReply("random.quota", "{user}", playerName, "{limit}", strconv.Itoa(g_appSettings.UserQueueLimit))
+
+		return
+	}
+
+	tracks, filterError := getRandomCandidates(arg)
+
+	if filterError != nil {
+		sendReply("random.invalid", "{user}", playerName, "{arg}", arg, "{error}", filterError.Error())
+
+		return
+	}
+
+	track := g_randomPicker.Pick(tracks)
+
+	if track == nil {
+		sendReply("random.notfound", "{user}", playerName, "{arg}", arg)
+
+		return
+	}
+
+	replyQueued("random", playerName, track, track.Queue(playerName))
+}
+
+func queueRandomShownTrack() {
+	if g_audioQueue.IsFull(g_appSettings.QueueLimit) {
+		logToEntry("Queue is full (%d entries)", g_appSettings.QueueLimit)
+
+		return
+	}
+
+	track := g_randomPicker.Pick(getExportTracks(true))
+
+	if track == nil {
+		logToEntry("No tracks to pick from")
+
+		return
+	}
+
+	go func() {
+		if position := track.Queue(""); position != 0 {
+			ui.QueueMain(func() { logToEntry("Queued %s at position %d", track.Name, position) })
+		}
+	}()
+}
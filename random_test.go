@@ -0,0 +1,122 @@
+package main
+
+import (
+	"testing"
+)
+
+const randomTestSeed = 42
+
+func TestRandomPickerHistory(t *testing.T) {
+	picker := NewRandomPicker(randomTestSeed, 3)
+	tracks := makeFuzzyTracks("one", "two", "three", "four", "five")
+
+	for index := 0; index < 500; index++ {
+		track := picker.Pick(tracks)
+
+		for _, item := range picker.History {
+			if item == track {
+				t.Fatalf("Pick %d returned %q, played within the last %d picks", index, track.Name, picker.HistorySize)
+			}
+		}
+
+		picker.Remember(track)
+	}
+
+	if len(picker.History) != 3 {
+		t.Fatalf("History holds %d tracks, want 3", len(picker.History))
+	}
+
+	picker.SetHistorySize(1)
+
+	if len(picker.History) != 1 {
+		t.Fatalf("SetHistorySize(1) kept %d tracks", len(picker.History))
+	}
+
+	picker.SetHistorySize(0)
+	picker.Remember(tracks[0])
+
+	if picker.History != nil {
+		t.Fatalf("A picker without history remembered %d tracks", len(picker.History))
+	}
+}
+
+func TestRandomPickerFallback(t *testing.T) {
+	picker := NewRandomPicker(randomTestSeed, 5)
+
+	if picker.Pick(nil) != nil {
+		t.Fatal("Pick without tracks returned a track")
+	}
+
+	tracks := makeFuzzyTracks("one", "two")
+
+	picker.Remember(tracks[0])
+	picker.Remember(tracks[1])
+
+	// Every candidate was played recently, so the history is ignored
+
+	seen := make(map[*AudioTrack]bool)
+
+	for index := 0; index < 50; index++ {
+		track := picker.Pick(tracks)
+
+		if track == nil {
+			t.Fatal("Pick returned nil when every track was played recently")
+		}
+
+		seen[track] = true
+	}
+
+	if len(seen) != len(tracks) {
+		t.Fatalf("Pick chose %d of the %d recent tracks", len(seen), len(tracks))
+	}
+
+	// Only the track outside of the history is left
+
+	picker.History = tracks[:1]
+
+	for index := 0; index < 50; index++ {
+		if track := picker.Pick(tracks); track != tracks[1] {
+			t.Fatalf("Pick returned %q from the history while another track was left", track.Name)
+		}
+	}
+}
+
+func TestRandomPickerWeights(t *testing.T) {
+	picker := NewRandomPicker(randomTestSeed, 0)
+	tracks := makeFuzzyTracks("heavy", "light", "unset")
+
+	tracks[0].Weight = 8
+	tracks[1].Weight = 1
+
+	counts := make(map[string]int)
+
+	for index := 0; index < 10000; index++ {
+		counts[picker.Pick(tracks).Name]++
+	}
+
+	// Weights 8, 1 and the default 1 give 80%, 10% and 10%
+
+	if counts["heavy"] < 7700 || counts["heavy"] > 8300 {
+		t.Errorf("The track weighted 8 was picked %d times out of 10000, want about 8000", counts["heavy"])
+	}
+
+	if counts["light"] < 800 || counts["light"] > 1200 || counts["unset"] < 800 || counts["unset"] > 1200 {
+		t.Errorf("Tracks weighted 1 were picked %d and %d times out of 10000, want about 1000", counts["light"], counts["unset"])
+	}
+}
+
+func TestRandomPickerSeed(t *testing.T) {
+	tracks := makeFuzzyTracks("one", "two", "three", "four", "five")
+	firstPicker, secondPicker := NewRandomPicker(randomTestSeed, 2), NewRandomPicker(randomTestSeed, 2)
+
+	for index := 0; index < 100; index++ {
+		firstTrack, secondTrack := firstPicker.Pick(tracks), secondPicker.Pick(tracks)
+
+		if firstTrack != secondTrack {
+			t.Fatalf("Pick %d returned %q and %q with the same seed", index, firstTrack.Name, secondTrack.Name)
+		}
+
+		firstPicker.Remember(firstTrack)
+		secondPicker.Remember(secondTrack)
+	}
+}
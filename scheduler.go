@@ -0,0 +1,437 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"waveboard/fixes/ui"
+)
+
+// ScheduledTask runs an action at a time of day or every few minutes, queueing its tracks.
+// Times are read from the scheduler clock, so the scheduler can be driven without waiting.
+
+type ScheduledTask struct {
+	Kind     string    `json:"kind"`
+	When     string    `json:"when"`
+	Action   string    `json:"action"`
+	Argument string    `json:"argument"`
+	Enabled  bool      `json:"enabled"`
+	NextRun  time.Time `json:"-"`
+}
+
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+// DelayedAction is a one-off action, such as a log rule with a delay.
+
+type DelayedAction struct {
+	Time time.Time
+	Run  func()
+}
+
+type Scheduler struct {
+	Clock   Clock
+	Delayed []*DelayedAction
+	Mutex   sync.Mutex
+}
+
+type ScheduleTableModel struct{}
+
+const (
+	scheduleDaily    = "daily"
+	scheduleInterval = "interval"
+)
+
+const scheduleTickInterval = time.Second
+
+var scheduleKindsList []string = []string{
+	scheduleDaily,
+	scheduleInterval,
+}
+
+var scheduleKindNames map[string]string = map[string]string{
+	scheduleDaily:    "At a time of day (hh:mm)",
+	scheduleInterval: "Every few minutes",
+}
+
+var g_scheduler *Scheduler = NewScheduler(SystemClock{})
+var g_scheduleModel *ui.TableModel
+
+func (clock SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func NewScheduler(clock Clock) *Scheduler {
+	return &Scheduler{Clock: clock}
+}
+
+// parseScheduleWhen checks the time of a task, hh:mm for daily tasks or minutes for the others.
+
+func parseScheduleWhen(kind string, when string) (time.Duration, error) {
+	when = strings.TrimSpace(when)
+
+	if kind == scheduleDaily {
+		dayTime, parseError := time.Parse("15:04", when)
+
+		if parseError != nil {
+			return 0, fmt.Errorf("\"%s\" is not a time of day, use hh:mm", when)
+		}
+
+		return time.Duration(dayTime.Hour())*time.Hour + time.Duration(dayTime.Minute())*time.Minute, nil
+	}
+
+	minutes, parseError := strconv.ParseFloat(when, 64)
+
+	if parseError != nil || minutes <= 0 {
+		return 0, fmt.Errorf("\"%s\" is not a positive number of minutes", when)
+	}
+
+	return time.Duration(minutes * float64(time.Minute)), nil
+}
+
+// NextRunAfter returns the first run of the task strictly after now.
+// Daily runs are built from the wall clock, as days are not 24 hours long when daylight saving time changes.
+
+func (task *ScheduledTask) NextRunAfter(now time.Time) time.Time {
+	offset, parseError := parseScheduleWhen(task.Kind, task.When)
+
+	if parseError != nil {
+		return time.Time{}
+	}
+
+	if task.Kind != scheduleDaily {
+		return now.Add(offset)
+	}
+
+	hour, minute := int(offset/time.Hour), int(offset%time.Hour/time.Minute)
+	year, month, day := now.Date()
+	nextRun := time.Date(year, month, day, hour, minute, 0, 0, now.Location())
+
+	if !nextRun.After(now) {
+		nextRun = time.Date(year, month, day+1, hour, minute, 0, 0, now.Location())
+	}
+
+	return nextRun
+}
+
+func (scheduler *Scheduler) After(delay time.Duration, run func()) {
+	scheduler.Mutex.Lock()
+	defer scheduler.Mutex.Unlock()
+
+	scheduler.Delayed = append(scheduler.Delayed, &DelayedAction{scheduler.Clock.Now().Add(delay), run})
+}
+
+// Due returns the tasks and delayed actions whose time has come, planning the next run of the tasks.
+// Runs missed while the computer was asleep are not caught up.
+
+func (scheduler *Scheduler) Due(tasks []*ScheduledTask) ([]*ScheduledTask, []*DelayedAction) {
+	scheduler.Mutex.Lock()
+	defer scheduler.Mutex.Unlock()
+
+	now := scheduler.Clock.Now()
+
+	var dueTasks []*ScheduledTask
+
+	for _, item := range tasks {
+		if !item.Enabled {
+			item.NextRun = time.Time{}
+
+			continue
+		}
+
+		if item.NextRun.IsZero() {
+			item.NextRun = item.NextRunAfter(now)
+
+			continue
+		}
+
+		if now.Before(item.NextRun) {
+			continue
+		}
+
+		dueTasks = append(dueTasks, item)
+
+		item.NextRun = item.NextRunAfter(now)
+	}
+
+	var dueActions []*DelayedAction
+
+	keptActions := scheduler.Delayed[:0]
+
+	for _, item := range scheduler.Delayed {
+		if now.Before(item.Time) {
+			keptActions = append(keptActions, item)
+
+			continue
+		}
+
+		dueActions = append(dueActions, item)
+	}
+
+	scheduler.Delayed = keptActions
+
+	return dueTasks, dueActions
+}
+
+func (scheduler *Scheduler) Clear() {
+	scheduler.Mutex.Lock()
+	defer scheduler.Mutex.Unlock()
+
+	scheduler.Delayed = nil
+}
+
+func (scheduler *Scheduler) Tick() {
+	g_logRulesMutex.Lock()
+	dueTasks, dueActions := scheduler.Due(g_appSettings.Schedules)
+
+	type taskRun struct {
+		Action   string
+		Argument string
+	}
+
+	runs := make([]taskRun, 0, len(dueTasks))
+
+	for _, item := range dueTasks {
+		runs = append(runs, taskRun{item.Action, item.Argument})
+	}
+
+	g_logRulesMutex.Unlock()
+
+	for _, item := range runs {
+		runTrackAction(item.Action, item.Argument, true)
+	}
+
+	for _, item := range dueActions {
+		item.Run()
+	}
+
+	if len(runs) != 0 && g_scheduleModel != nil {
+		ui.QueueMain(func() { g_scheduleModel.RowInserted(0) })
+	}
+}
+
+func setupScheduler() {
+	go func() {
+		for range time.Tick(scheduleTickInterval) {
+			g_scheduler.Tick()
+		}
+	}()
+}
+
+func refreshSchedule() {
+	if g_scheduleModel != nil {
+		g_scheduleModel.RowInserted(0)
+	}
+}
+
+func makeScheduleTab() ui.Control {
+	vContainer := ui.NewVerticalBox()
+	vContainer.SetPadded(true)
+
+	scheduleForm := ui.NewForm()
+	scheduleForm.SetPadded(true)
+
+	kindsComboBox := ui.NewCombobox()
+
+	for _, item := range scheduleKindsList {
+		kindsComboBox.Append(scheduleKindNames[item])
+	}
+
+	kindsComboBox.SetSelected(0)
+
+	scheduleForm.Append("Run :", kindsComboBox, false)
+
+	whenEntry := ui.NewEntry()
+
+	scheduleForm.Append("Time or minutes :", whenEntry, false)
+
+	actionsComboBox := ui.NewCombobox()
+
+	for _, item := range logRuleActionsList {
+		actionsComboBox.Append(logRuleActionNames[item])
+	}
+
+	actionsComboBox.SetSelected(0)
+
+	scheduleForm.Append("Action :", actionsComboBox, false)
+
+	addGrid := ui.NewGrid()
+	addGrid.SetPadded(true)
+
+	argumentEntry := ui.NewEntry()
+
+	addGrid.Append(argumentEntry, 0, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	addButton := ui.NewButton("Add task")
+	addButton.OnClicked(func(b *ui.Button) {
+		task := &ScheduledTask{
+			Kind:     scheduleKindsList[kindsComboBox.Selected()],
+			When:     strings.TrimSpace(whenEntry.Text()),
+			Action:   logRuleActionsList[actionsComboBox.Selected()],
+			Argument: strings.TrimSpace(argumentEntry.Text()),
+			Enabled:  true,
+		}
+
+		if _, parseError := parseScheduleWhen(task.Kind, task.When); parseError != nil {
+			logToEntry(parseError.Error())
+
+			return
+		}
+
+		g_logRulesMutex.Lock()
+		g_appSettings.Schedules = append(g_appSettings.Schedules, task)
+		g_logRulesMutex.Unlock()
+
+		whenEntry.SetText("")
+		argumentEntry.SetText("")
+
+		refreshSchedule()
+
+		go trySaveSettings()
+	})
+
+	addGrid.Append(addButton, 1, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	scheduleForm.Append("Track, category or text :", addGrid, false)
+
+	g_scheduleModel = ui.NewTableModel(&ScheduleTableModel{})
+	scheduleTable := ui.NewTable(&ui.TableParams{
+		Model:                         g_scheduleModel,
+		RowBackgroundColorModelColumn: 7,
+	})
+
+	scheduleTable.AppendCheckboxColumn("Enabled", 0, ui.TableModelColumnAlwaysEditable)
+	scheduleTable.AppendTextColumn("Run", 1, ui.TableModelColumnNeverEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	scheduleTable.AppendTextColumn("Time or minutes", 2, ui.TableModelColumnAlwaysEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	scheduleTable.AppendTextColumn("Action", 3, ui.TableModelColumnNeverEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	scheduleTable.AppendTextColumn("Argument", 4, ui.TableModelColumnAlwaysEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	scheduleTable.AppendTextColumn("Next run", 5, ui.TableModelColumnNeverEditable, &ui.TableTextColumnOptionalParams{ColorModelColumn: -1})
+	scheduleTable.AppendButtonColumn("Remove", 6, ui.TableModelColumnAlwaysEditable)
+
+	refreshSchedule()
+
+	vContainer.Append(scheduleForm, false)
+	vContainer.Append(scheduleTable, true)
+	vContainer.Append(ui.NewLabel("Scheduled tracks are added to the queue, TTS is spoken right away."), false)
+	vContainer.Append(ui.NewLabel("Every few minutes counts from the start of the application or from when the task is enabled."), false)
+
+	return vContainer
+}
+
+func (mh *ScheduleTableModel) ColumnTypes(m *ui.TableModel) []ui.TableValue {
+	return []ui.TableValue{
+		ui.TableInt(0),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableString(""),
+		ui.TableColor{},
+	}
+}
+
+func (mh *ScheduleTableModel) NumRows(m *ui.TableModel) int {
+	if len(g_appSettings.Schedules) == 0 {
+		return 0
+	}
+
+	return len(g_appSettings.Schedules) - 1
+}
+
+func (mh *ScheduleTableModel) CellValue(m *ui.TableModel, row, column int) ui.TableValue {
+	if column == 7 {
+		if row%2 == 0 {
+			return ui.TableColor{R: 0, G: 0, B: 0, A: 0.05}
+		}
+
+		return nil
+	}
+
+	if row >= len(g_appSettings.Schedules) {
+		if column == 0 {
+			return ui.TableInt(0)
+		}
+
+		return ui.TableString("")
+	}
+
+	task := g_appSettings.Schedules[row]
+
+	switch column {
+	case 0:
+		if task.Enabled {
+			return ui.TableInt(1)
+		}
+
+		return ui.TableInt(0)
+	case 1:
+		return ui.TableString(scheduleKindNames[task.Kind])
+	case 2:
+		return ui.TableString(task.When)
+	case 3:
+		return ui.TableString(logRuleActionNames[task.Action])
+	case 4:
+		return ui.TableString(task.Argument)
+	case 5:
+		if task.NextRun.IsZero() {
+			return ui.TableString("")
+		}
+
+		return ui.TableString(task.NextRun.Format("Mon 15:04:05"))
+	case 6:
+		return ui.TableString("Remove")
+	}
+
+	return nil
+}
+
+func (mh *ScheduleTableModel) SetCellValue(m *ui.TableModel, row, column int, value ui.TableValue) {
+	if row >= len(g_appSettings.Schedules) {
+		return
+	}
+
+	g_logRulesMutex.Lock()
+	defer g_logRulesMutex.Unlock()
+
+	task := g_appSettings.Schedules[row]
+
+	if value == nil {
+		switch column {
+		case 6:
+			g_appSettings.Schedules = append(g_appSettings.Schedules[:row], g_appSettings.Schedules[row+1:]...)
+
+			m.RowInserted(0)
+
+			go trySaveSettings()
+		}
+
+		return
+	}
+
+	switch column {
+	case 0:
+		task.Enabled = value.(ui.TableInt) == 1
+	case 2:
+		newWhen := strings.TrimSpace(string(value.(ui.TableString)))
+
+		if _, parseError := parseScheduleWhen(task.Kind, newWhen); parseError != nil {
+			logToEntry(parseError.Error())
+
+			return
+		}
+
+		task.When = newWhen
+		task.NextRun = time.Time{}
+	case 4:
+		task.Argument = strings.TrimSpace(string(value.(ui.TableString)))
+	}
+
+	go trySaveSettings()
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	Time time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.Time
+}
+
+func TestParseScheduleWhen(t *testing.T) {
+	testCases := []struct {
+		Kind   string
+		When   string
+		Offset time.Duration
+		Valid  bool
+	}{
+		{scheduleDaily, "08:30", 8*time.Hour + 30*time.Minute, true},
+		{scheduleDaily, " 23:59 ", 23*time.Hour + 59*time.Minute, true},
+		{scheduleDaily, "00:00", 0, true},
+		{scheduleDaily, "24:00", 0, false},
+		{scheduleDaily, "8h30", 0, false},
+		{scheduleInterval, "15", 15 * time.Minute, true},
+		{scheduleInterval, "0.5", 30 * time.Second, true},
+		{scheduleInterval, "0", 0, false},
+		{scheduleInterval, "-5", 0, false},
+		{scheduleInterval, "soon", 0, false},
+	}
+
+	for _, testCase := range testCases {
+		offset, parseError := parseScheduleWhen(testCase.Kind, testCase.When)
+
+		if (parseError == nil) != testCase.Valid || offset != testCase.Offset {
+			t.Errorf("parseScheduleWhen(%q, %q) returned %v, %v", testCase.Kind, testCase.When, offset, parseError)
+		}
+	}
+}
+
+func TestNextRunAfterDaily(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2026, time.October, 18, 21, 0, 0, 0, location)
+
+	testCases := []struct {
+		When    string
+		NextRun time.Time
+	}{
+		{"21:01", time.Date(2026, time.October, 18, 21, 1, 0, 0, location)},
+		{"21:00", time.Date(2026, time.October, 19, 21, 0, 0, 0, location)},
+		{"08:30", time.Date(2026, time.October, 19, 8, 30, 0, 0, location)},
+		{"00:00", time.Date(2026, time.October, 19, 0, 0, 0, 0, location)},
+	}
+
+	for _, testCase := range testCases {
+		task := &ScheduledTask{Kind: scheduleDaily, When: testCase.When}
+
+		if nextRun := task.NextRunAfter(now); !nextRun.Equal(testCase.NextRun) {
+			t.Errorf("NextRunAfter for %s returned %v, want %v", testCase.When, nextRun, testCase.NextRun)
+		}
+	}
+
+	// The last day of the month rolls over to the next month
+
+	task := &ScheduledTask{Kind: scheduleDaily, When: "06:00"}
+
+	if nextRun := task.NextRunAfter(time.Date(2026, time.December, 31, 7, 0, 0, 0, location)); !nextRun.Equal(time.Date(2027, time.January, 1, 6, 0, 0, 0, location)) {
+		t.Errorf("NextRunAfter on the last day of the year returned %v", nextRun)
+	}
+
+	task.When = "not a time"
+
+	if nextRun := task.NextRunAfter(now); !nextRun.IsZero() {
+		t.Errorf("NextRunAfter for an invalid time returned %v", nextRun)
+	}
+}
+
+func TestNextRunAfterDaylightSaving(t *testing.T) {
+	location, locationError := time.LoadLocation("Europe/Paris")
+
+	if locationError != nil {
+		t.Skip("No time zone database : ", locationError)
+	}
+
+	// Clocks go back on the 25th of October and forward on the 29th of March
+
+	testCases := []struct {
+		Now     time.Time
+		NextRun time.Time
+	}{
+		{time.Date(2026, time.October, 24, 9, 0, 0, 0, location), time.Date(2026, time.October, 25, 8, 0, 0, 0, location)},
+		{time.Date(2026, time.October, 25, 1, 0, 0, 0, location), time.Date(2026, time.October, 25, 8, 0, 0, 0, location)},
+		{time.Date(2026, time.March, 28, 9, 0, 0, 0, location), time.Date(2026, time.March, 29, 8, 0, 0, 0, location)},
+		{time.Date(2026, time.March, 29, 1, 0, 0, 0, location), time.Date(2026, time.March, 29, 8, 0, 0, 0, location)},
+	}
+
+	task := &ScheduledTask{Kind: scheduleDaily, When: "08:00"}
+
+	for _, testCase := range testCases {
+		nextRun := task.NextRunAfter(testCase.Now)
+
+		if !nextRun.Equal(testCase.NextRun) || nextRun.Hour() != 8 || nextRun.Minute() != 0 {
+			t.Errorf("NextRunAfter(%v) returned %v, want %v", testCase.Now, nextRun, testCase.NextRun)
+		}
+	}
+}
+
+func TestSchedulerDue(t *testing.T) {
+	clock := &fakeClock{time.Date(2026, time.October, 18, 10, 0, 0, 0, time.UTC)}
+	scheduler := NewScheduler(clock)
+
+	intervalTask := &ScheduledTask{Kind: scheduleInterval, When: "5", Action: "play", Argument: "interval", Enabled: true}
+	dailyTask := &ScheduledTask{Kind: scheduleDaily, When: "10:30", Action: "play", Argument: "daily", Enabled: true}
+	disabledTask := &ScheduledTask{Kind: scheduleInterval, When: "1", Action: "play", Argument: "disabled"}
+	tasks := []*ScheduledTask{intervalTask, dailyTask, disabledTask}
+
+	dueAt := func(minutes int, wantTasks ...*ScheduledTask) {
+		clock.Time = time.Date(2026, time.October, 18, 10, minutes, 0, 0, time.UTC)
+
+		dueTasks, _ := scheduler.Due(tasks)
+
+		if len(dueTasks) != len(wantTasks) {
+			t.Fatalf("%d tasks were due at 10:%02d, want %d", len(dueTasks), minutes, len(wantTasks))
+		}
+
+		for index, item := range dueTasks {
+			if item != wantTasks[index] {
+				t.Fatalf("Task %q was due at 10:%02d, want %q", item.Argument, minutes, wantTasks[index].Argument)
+			}
+		}
+	}
+
+	// The first pass only plans the runs
+
+	dueAt(0)
+
+	if !intervalTask.NextRun.Equal(clock.Time.Add(5*time.Minute)) || !dailyTask.NextRun.Equal(clock.Time.Add(30*time.Minute)) || !disabledTask.NextRun.IsZero() {
+		t.Fatalf("First runs were planned at %v, %v and %v", intervalTask.NextRun, dailyTask.NextRun, disabledTask.NextRun)
+	}
+
+	dueAt(4)
+	dueAt(5, intervalTask)
+	dueAt(9)
+	dueAt(10, intervalTask)
+
+	// Runs missed while asleep are not caught up
+
+	dueAt(30, intervalTask, dailyTask)
+
+	if !intervalTask.NextRun.Equal(clock.Time.Add(5 * time.Minute)) {
+		t.Fatalf("Interval task was planned at %v after a long sleep", intervalTask.NextRun)
+	}
+
+	if !dailyTask.NextRun.Equal(time.Date(2026, time.October, 19, 10, 30, 0, 0, time.UTC)) {
+		t.Fatalf("Daily task was planned at %v after running", dailyTask.NextRun)
+	}
+
+	intervalTask.Enabled = false
+
+	dueAt(35)
+
+	if !intervalTask.NextRun.IsZero() {
+		t.Fatal("Disabling a task kept its next run")
+	}
+
+	// Enabling a task counts again from that moment
+
+	intervalTask.Enabled = true
+
+	dueAt(37)
+	dueAt(41)
+	dueAt(42, intervalTask)
+}
+
+func TestSchedulerDelayedActions(t *testing.T) {
+	clock := &fakeClock{time.Date(2026, time.October, 18, 10, 0, 0, 0, time.UTC)}
+	scheduler := NewScheduler(clock)
+
+	var runs []string
+
+	scheduler.After(2*time.Second, func() { runs = append(runs, "short") })
+	scheduler.After(10*time.Second, func() { runs = append(runs, "long") })
+	scheduler.After(0, func() { runs = append(runs, "now") })
+
+	runDue := func(elapsed time.Duration) int {
+		clock.Time = time.Date(2026, time.October, 18, 10, 0, 0, 0, time.UTC).Add(elapsed)
+
+		_, dueActions := scheduler.Due(nil)
+
+		for _, item := range dueActions {
+			item.Run()
+		}
+
+		return len(dueActions)
+	}
+
+	if count := runDue(0); count != 1 || runs[0] != "now" {
+		t.Fatalf("%d actions ran right away : %q", count, runs)
+	}
+
+	if count := runDue(time.Second); count != 0 {
+		t.Fatalf("%d actions ran before their delay", count)
+	}
+
+	if count := runDue(2 * time.Second); count != 1 || runs[1] != "short" {
+		t.Fatalf("%d actions ran after 2 seconds : %q", count, runs)
+	}
+
+	if count := runDue(3 * time.Second); count != 0 {
+		t.Fatalf("An action ran twice : %q", runs)
+	}
+
+	scheduler.Clear()
+
+	if count := runDue(time.Minute); count != 0 || len(scheduler.Delayed) != 0 {
+		t.Fatalf("%d actions ran after Clear : %q", count, runs)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"waveboard/fixes/ui"
+
+	"github.com/bep/debounce"
+)
+
+// SavedQueue is written to waveboard.queue.json whenever the queue changes,
+// so the queue and the current track survive a restart.
+
+type SavedQueue struct {
+	Current *SavedQueueEntry   `json:"current,omitempty"`
+	Entries []*SavedQueueEntry `json:"entries"`
+}
+
+type SavedQueueEntry struct {
+	Path      string `json:"path"`
+	Requester string `json:"requester,omitempty"`
+	Frame     int64  `json:"frame,omitempty"`
+}
+
+const queueFileName = "waveboard.queue.json"
+
+var g_saveQueueFunc = debounce.New(500 * time.Millisecond)
+var g_queueRestored bool
+
+// A restored queue stays paused until resumeQueue is called.
+
+var g_queuePaused bool
+var g_pausedEntry *QueueEntry
+var g_pausedFrame int64
+
+func getQueueFilePath() (string, error) {
+	currentPath, wdError := os.Getwd()
+
+	if wdError != nil {
+		return "", wdError
+	}
+
+	return filepath.Join(currentPath, queueFileName), nil
+}
+
+func makeSavedQueue() *SavedQueue {
+	savedQueue := &SavedQueue{}
+
+	g_stopMutex.Lock()
+
+	if g_currentTrack != nil && g_currentTrack.Path != "" {
+		savedQueue.Current = &SavedQueueEntry{g_currentTrack.Path, g_currentRequester, g_playbackFrame.Load()}
+	} else if g_pausedEntry != nil {
+		savedQueue.Current = &SavedQueueEntry{g_pausedEntry.Track.Path, g_pausedEntry.Requester, g_pausedFrame}
+	}
+
+	g_stopMutex.Unlock()
+
+	g_audioQueue.ForEach(func(index int, entry *QueueEntry) {
+		savedQueue.Entries = append(savedQueue.Entries, &SavedQueueEntry{entry.Track.Path, entry.Requester, 0})
+	})
+
+	return savedQueue
+}
+
+func saveQueue() error {
+	// Saving before the restore would overwrite the previous session
+
+	if !g_queueRestored {
+		return nil
+	}
+
+	queuePath, pathError := getQueueFilePath()
+
+	if pathError != nil {
+		return pathError
+	}
+
+	jsonQueue, marshalError := json.MarshalIndent(makeSavedQueue(), "", "\t")
+
+	if marshalError != nil {
+		return marshalError
+	}
+
+	jsonQueue = []byte(strings.ReplaceAll(string(jsonQueue), "\n", "\r\n"))
+
+	return os.WriteFile(queuePath, jsonQueue, 0644)
+}
+
+func trySaveQueue() {
+	g_saveQueueFunc(func() {
+		if saveError := saveQueue(); saveError != nil {
+			ui.QueueMain(func() { logToEntry(saveError.Error()) })
+		}
+	})
+}
+
+// restoreQueueTrack returns nil for files which were deleted or are no longer in a library.
+
+func restoreQueueTrack(trackPath string) *AudioTrack {
+	if _, statError := os.Stat(trackPath); statError != nil {
+		logToEntry("Dropped queued track %s, the file no longer exists", trackPath)
+
+		return nil
+	}
+
+	track := findTrackByPath(trackPath)
+
+	if track == nil {
+		logToEntry("Dropped queued track %s, the file is no longer in a library", trackPath)
+	}
+
+	return track
+}
+
+func restoreQueue() {
+	defer func() { g_queueRestored = true }()
+
+	queuePath, pathError := getQueueFilePath()
+
+	if pathError != nil {
+		logToEntry(pathError.Error())
+
+		return
+	}
+
+	queueContents, readError := os.ReadFile(queuePath)
+
+	if errors.Is(readError, fs.ErrNotExist) {
+		return
+	}
+
+	if readError != nil {
+		logToEntry(readError.Error())
+
+		return
+	}
+
+	savedQueue := &SavedQueue{}
+
+	if jsonError := json.Unmarshal(queueContents, savedQueue); jsonError != nil {
+		logToEntry("Could not restore the queue : %s", jsonError.Error())
+
+		return
+	}
+
+	if savedQueue.Current != nil {
+		if track := restoreQueueTrack(savedQueue.Current.Path); track != nil {
+			g_pausedEntry = &QueueEntry{track, savedQueue.Current.Requester}
+			g_pausedFrame = savedQueue.Current.Frame
+		}
+	}
+
+	entries := make([]*QueueEntry, 0, len(savedQueue.Entries))
+
+	for _, item := range savedQueue.Entries {
+		if track := restoreQueueTrack(item.Path); track != nil {
+			entries = append(entries, &QueueEntry{track, item.Requester})
+		}
+	}
+
+	g_audioQueue.Restore(entries)
+
+	if g_pausedEntry == nil && len(entries) == 0 {
+		return
+	}
+
+	g_queuePaused = true
+	g_queueModel.RowInserted(0)
+
+	if g_appSettings.ResumeQueue {
+		logToEntry("Restored %d queued tracks", len(entries))
+
+		resumeQueue()
+
+		return
+	}
+
+	logToEntry("Restored %d queued tracks, paused until resumed from the queue tab", len(entries))
+}
+
+// resumeQueue continues the restored track where it stopped, or starts the next queued one.
+
+func resumeQueue() {
+	if !g_queuePaused {
+		return
+	}
+
+	g_queuePaused = false
+
+	if g_pausedEntry != nil {
+		pausedEntry, startFrame := g_pausedEntry, g_pausedFrame
+
+		g_pausedEntry = nil
+		g_pausedFrame = 0
+
+		go tryPlaySoundFrom(pausedEntry.Track, g_selectedDevice, startFrame, pausedEntry.Requester)
+
+		return
+	}
+
+	if g_currentTrack != nil {
+		return
+	}
+
+	nextEntry := g_audioQueue.PopFront()
+
+	if nextEntry == nil {
+		return
+	}
+
+	go tryPlaySoundFrom(nextEntry.Track, g_selectedDevice, 0, nextEntry.Requester)
+
+	refreshQueue()
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"waveboard/fixes/gosndfile/sndfile"
+	"waveboard/fixes/ui"
+
+	"github.com/moutend/go-hook/pkg/types"
+)
+
+// Pausing and seeking are applied by DataFunc, which owns the decoder of the current track.
+// A paused track keeps its device running and outputs silence.
+
+var g_trackPaused atomic.Bool
+
+// g_seekRequest holds the requested frame plus one, 0 meaning no request.
+
+var g_seekRequest atomic.Int64
+var g_playbackFrame atomic.Int64
+
+var g_bindingAction string
+var g_transportButtons map[string]*ui.Button = make(map[string]*ui.Button)
+var g_updatingTimeline bool
+
+const defaultSeekStep float32 = 10.0
+const timelineSteps = 1000
+
+var noTrackError = errors.New("Nothing is playing")
+
+var transportActionsList []string = []string{
+	"pause",
+	"seekback",
+	"seekforward",
+	"skip",
+	"stopall",
+	"clearqueue",
+	"replay",
+	"volumeup",
+	"volumedown",
+	"mute",
+	"previousbank",
+	"nextbank",
+}
+
+var transportActionNames map[string]string = map[string]string{
+	"pause":        "Pause or resume",
+	"seekback":     "Seek back",
+	"seekforward":  "Seek forward",
+	"skip":         "Skip",
+	"stopall":      "Stop all",
+	"clearqueue":   "Clear queue",
+	"replay":       "Replay last",
+	"volumeup":     "Global volume up",
+	"volumedown":   "Global volume down",
+	"mute":         "Mute or unmute",
+	"previousbank": "Previous bank",
+	"nextbank":     "Next bank",
+}
+
+func (track *AudioTrack) FrameToDuration(frame int64) time.Duration {
+	return time.Duration(frame) * time.Second / time.Duration(track.Virtual.Format.Samplerate)
+}
+
+func (track *AudioTrack) DurationToFrame(duration time.Duration) int64 {
+	frame := int64(duration * time.Duration(track.Virtual.Format.Samplerate) / time.Second)
+
+	if frame < 0 {
+		return 0
+	}
+
+	if frame > track.Virtual.Format.Frames {
+		return track.Virtual.Format.Frames
+	}
+
+	return frame
+}
+
+// applySeekRequest runs in DataFunc, before the next frames are read.
+
+func applySeekRequest(track *AudioTrack) {
+	requestedFrame := g_seekRequest.Swap(0)
+
+	if requestedFrame == 0 {
+		return
+	}
+
+	if _, seekError := track.Virtual.Seek(requestedFrame-1, sndfile.Set); seekError != nil {
+		return
+	}
+
+	track.Resampler.Reset()
+	g_audioBuffer.Reset()
+	track.ReadMode = false
+
+	g_playbackFrame.Store(requestedFrame - 1)
+}
+
+// updatePlaybackFrame runs in DataFunc and leaves out the frames still waiting in the output buffer.
+// Output frames hold two float32 samples.
+
+func updatePlaybackFrame(track *AudioTrack) {
+	readFrame, seekError := track.Virtual.Seek(0, sndfile.Current)
+
+	if seekError != nil {
+		return
+	}
+
+	playbackFrame := readFrame - int64(float64(g_audioBuffer.Len()/8)/track.SampleRatio)
+
+	if playbackFrame < 0 {
+		playbackFrame = 0
+	}
+
+	g_playbackFrame.Store(playbackFrame)
+}
+
+// resetTransport is called for every new track, which starts unpaused.
+
+func resetTransport(startFrame int64) {
+	g_seekRequest.Store(0)
+	g_playbackFrame.Store(startFrame)
+	g_trackPaused.Store(false)
+}
+
+// getPlaybackPosition returns the current track with its position and duration, read under g_stopMutex
+// as DataFunc replaces the track and closes its file when it ends.
+
+func getPlaybackPosition() (*AudioTrack, time.Duration, time.Duration) {
+	g_stopMutex.Lock()
+	defer g_stopMutex.Unlock()
+
+	return readPlaybackPosition()
+}
+
+// readPlaybackPosition is getPlaybackPosition for callers already holding g_stopMutex.
+
+func readPlaybackPosition() (*AudioTrack, time.Duration, time.Duration) {
+	track := g_currentTrack
+
+	if track == nil || track.Virtual == nil {
+		return nil, 0, 0
+	}
+
+	playbackFrame := g_playbackFrame.Load()
+
+	if requestedFrame := g_seekRequest.Load(); requestedFrame != 0 {
+		playbackFrame = requestedFrame - 1
+	}
+
+	return track, track.FrameToDuration(playbackFrame), track.FrameToDuration(track.Virtual.Format.Frames)
+}
+
+func seekCurrentTrack(offset time.Duration, relative bool) (*AudioTrack, time.Duration, error) {
+	g_stopMutex.Lock()
+	defer g_stopMutex.Unlock()
+
+	track, position, _ := readPlaybackPosition()
+
+	if track == nil {
+		return nil, 0, noTrackError
+	}
+
+	if !relative {
+		position = 0
+	}
+
+	targetFrame := track.DurationToFrame(position + offset)
+
+	g_seekRequest.Store(targetFrame + 1)
+
+	return track, track.FrameToDuration(targetFrame), nil
+}
+
+// skipCurrentTrack seeks to the end, so DataFunc moves on to the next queued track.
+
+func skipCurrentTrack() {
+	g_stopMutex.Lock()
+	defer g_stopMutex.Unlock()
+
+	if g_currentTrack == nil || g_currentTrack.Virtual == nil {
+		return
+	}
+
+	g_seekRequest.Store(g_currentTrack.Virtual.Format.Frames + 1)
+	g_trackPaused.Store(false)
+}
+
+func setTrackPaused(paused bool) *AudioTrack {
+	track := g_currentTrack
+
+	if track == nil || g_trackPaused.Load() == paused {
+		return nil
+	}
+
+	g_trackPaused.Store(paused)
+
+	return track
+}
+
+func togglePause() {
+	setTrackPaused(!g_trackPaused.Load())
+}
+
+// parseSeekArgument accepts seconds, m:ss, h:mm:ss or Go durations, relative when they start with + or -.
+
+func parseSeekArgument(text string) (time.Duration, bool, error) {
+	text = strings.TrimSpace(text)
+	formatError := fmt.Errorf("\"%s\" is not a time, use 90, 1:30, +10s or -5s", text)
+
+	relative := strings.HasPrefix(text, "+") || strings.HasPrefix(text, "-")
+	negative := strings.HasPrefix(text, "-")
+	body := strings.TrimLeft(text, "+-")
+
+	var offset time.Duration
+
+	if strings.Contains(body, ":") {
+		for _, item := range strings.Split(body, ":") {
+			value, parseError := strconv.ParseUint(item, 10, 32)
+
+			if parseError != nil {
+				return 0, false, formatError
+			}
+
+			offset = offset*60 + time.Duration(value)*time.Second
+		}
+	} else if seconds, parseError := strconv.ParseFloat(body, 64); parseError == nil && seconds >= 0 {
+		offset = time.Duration(seconds * float64(time.Second))
+	} else if duration, durationError := time.ParseDuration(body); durationError == nil && duration >= 0 {
+		offset = duration
+	} else {
+		return 0, false, formatError
+	}
+
+	if negative {
+		offset = -offset
+	}
+
+	return offset, relative, nil
+}
+
+func getSeekStep() time.Duration {
+	return time.Duration(g_appSettings.SeekStep * float32(time.Second))
+}
+
+func runTransportAction(action string) {
+	switch action {
+	case "pause":
+		togglePause()
+	case "seekback":
+		seekCurrentTrack(-getSeekStep(), true)
+	case "seekforward":
+		seekCurrentTrack(getSeekStep(), true)
+	case "skip":
+		skipCurrentTrack()
+	case "stopall":
+		stopAll()
+	case "clearqueue":
+		clearQueue()
+	case "replay":
+		replayLastTrack()
+	case "volumeup":
+		stepGlobalVolume(g_appSettings.VolumeStep)
+	case "volumedown":
+		stepGlobalVolume(-g_appSettings.VolumeStep)
+	case "mute":
+		toggleMute()
+	case "previousbank":
+		ui.QueueMain(func() { cycleBank(-1) })
+	case "nextbank":
+		ui.QueueMain(func() { cycleBank(1) })
+	}
+}
+
+func findTransportAction(key types.VKCode) string {
+	for action, item := range g_appSettings.TransportKeys {
+		if item == key {
+			return action
+		}
+	}
+
+	return ""
+}
+
+func unbindTransportKey(key types.VKCode) {
+	if action := findTransportAction(key); action != "" {
+		delete(g_appSettings.TransportKeys, action)
+
+		ui.QueueMain(func() {
+			logToEntry("Unbound %s from the %s hotkey", formatKey(key), strings.ToLower(transportActionNames[action]))
+			updateTransportButton(action)
+		})
+	}
+}
+
+// bindTransportKey takes the key away from any action, macro or track which used it, in every bank.
+
+func bindTransportKey(key types.VKCode) {
+	action := g_bindingAction
+	g_bindingAction = ""
+
+	if key == deleteKey {
+		delete(g_appSettings.TransportKeys, action)
+	} else {
+		clearKeyBindings(key)
+		unbindTransportKey(key)
+		unbindMacroKey(key)
+
+		g_appSettings.TransportKeys[action] = key
+	}
+
+	ui.QueueMain(func() { updateTransportButton(action) })
+
+	go trySaveSettings()
+}
+
+func cancelTransportBinding() {
+	if g_bindingAction == "" {
+		return
+	}
+
+	action := g_bindingAction
+	g_bindingAction = ""
+
+	updateTransportButton(action)
+}
+
+func updateTransportButton(action string) {
+	button, exists := g_transportButtons[action]
+
+	if !exists {
+		return
+	}
+
+	if g_bindingAction == action {
+		button.SetText("Binding to ...")
+
+		return
+	}
+
+	if key, bound := g_appSettings.TransportKeys[action]; bound {
+		button.SetText(formatKey(key))
+
+		return
+	}
+
+	button.SetText("Bind to key")
+}
+
+func formatPosition(position time.Duration) string {
+	if position < time.Second {
+		return "0:00"
+	}
+
+	return formatDuration(position)
+}
+
+func makePlaybackGrid() ui.Control {
+	playbackGrid := ui.NewGrid()
+	playbackGrid.SetPadded(true)
+
+	pauseButton := ui.NewButton("Pause")
+	pauseButton.OnClicked(func(b *ui.Button) {
+		togglePause()
+	})
+
+	playbackGrid.Append(pauseButton, 0, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	timelineSlider := ui.NewSlider(0, timelineSteps)
+	timelineSlider.OnChanged(func(s *ui.Slider) {
+		if g_updatingTimeline {
+			return
+		}
+
+		_, _, duration := getPlaybackPosition()
+
+		seekCurrentTrack(duration*time.Duration(s.Value())/timelineSteps, false)
+	})
+
+	playbackGrid.Append(timelineSlider, 1, 0, 1, 1, true, ui.AlignFill, false, ui.AlignCenter)
+
+	positionLabel := ui.NewLabel("")
+
+	playbackGrid.Append(positionLabel, 2, 0, 1, 1, false, ui.AlignFill, false, ui.AlignCenter)
+
+	go func() {
+		for range time.Tick(250 * time.Millisecond) {
+			ui.QueueMain(func() {
+				track, position, duration := getPlaybackPosition()
+
+				g_updatingTimeline = true
+
+				if track == nil || duration <= 0 {
+					pauseButton.SetText("Pause")
+					timelineSlider.SetValue(0)
+					positionLabel.SetText("")
+				} else {
+					if g_trackPaused.Load() {
+						pauseButton.SetText("Resume")
+					} else {
+						pauseButton.SetText("Pause")
+					}
+
+					timelineSlider.SetValue(int(position * timelineSteps / duration))
+					positionLabel.SetText(formatPosition(position) + " / " + formatPosition(duration))
+				}
+
+				g_updatingTimeline = false
+			})
+		}
+	}()
+
+	return playbackGrid
+}
+
+func pauseCommand(playerName string, arg string) {
+	if track := setTrackPaused(true); track != nil {
+		sendReply("pause.paused", "{user}", playerName, "{id}", track.Label(), "{name}", track.Name)
+	}
+}
+
+func resumeCommand(playerName string, arg string) {
+	if track := setTrackPaused(false); track != nil {
+		sendReply("resume.resumed", "{user}", playerName, "{id}", track.Label(), "{name}", track.Name)
+	}
+}
+
+func seekCommand(playerName string, arg string) {
+	if arg == "" || g_currentTrack == nil {
+		return
+	}
+
+	offset, relative, parseError := parseSeekArgument(arg)
+
+	if parseError != nil {
+		sendReply("seek.invalid", "{user}", playerName, "{arg}", arg, "{error}", parseError.Error())
+
+		return
+	}
+
+	track, position, seekError := seekCurrentTrack(offset, relative)
+
+	if seekError != nil {
+		return
+	}
+
+	sendReply("seek.seeked", "{user}", playerName, "{id}", track.Label(), "{name}", track.Name, "{position}", formatPosition(position))
+}
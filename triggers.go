@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/moutend/go-hook/pkg/types"
+)
+
+// Trigger modes decide what the binding of a track does, the default one restarting the track on every press.
+
+const (
+	triggerOneShot = iota
+	triggerHold
+	triggerToggle
+	triggerQueue
+)
+
+var triggerModeNames []string = []string{
+	"One-shot",
+	"Hold to play",
+	"Toggle",
+	"Queue",
+}
+
+// g_heldKeys holds the keys which are down, as windows repeats WM_KEYDOWN without any key-up while a key is held.
+
+var g_heldKeys map[types.VKCode]bool = make(map[types.VKCode]bool)
+var g_heldMutex sync.Mutex
+
+func (track *AudioTrack) TriggerName() string {
+	if track.TriggerMode < 0 || track.TriggerMode >= len(triggerModeNames) {
+		return triggerModeNames[triggerOneShot]
+	}
+
+	return triggerModeNames[track.TriggerMode]
+}
+
+func (track *AudioTrack) CycleTriggerMode() {
+	if track.TriggerMode < 0 {
+		track.TriggerMode = triggerOneShot
+	}
+
+	track.TriggerMode = (track.TriggerMode + 1) % len(triggerModeNames)
+}
+
+// pressKey returns false for repeated presses of a held key.
+
+func pressKey(key types.VKCode) bool {
+	g_heldMutex.Lock()
+	defer g_heldMutex.Unlock()
+
+	if g_heldKeys[key] {
+		return false
+	}
+
+	g_heldKeys[key] = true
+
+	return true
+}
+
+func releaseKey(key types.VKCode) {
+	g_heldMutex.Lock()
+	defer g_heldMutex.Unlock()
+
+	delete(g_heldKeys, key)
+}
+
+func isKeyHeld(key types.VKCode) bool {
+	g_heldMutex.Lock()
+	defer g_heldMutex.Unlock()
+
+	return g_heldKeys[key]
+}
+
+func isCurrentTrack(track *AudioTrack) bool {
+	g_stopMutex.Lock()
+	defer g_stopMutex.Unlock()
+
+	return g_currentTrack == track
+}
+
+// stopTrack ends the track if it is still the current one, the queue going on as usual.
+
+func stopTrack(track *AudioTrack) {
+	if !isCurrentTrack(track) {
+		return
+	}
+
+	skipCurrentTrack()
+}
+
+// playHeldTrack stops the track right away when the key or pad was released while it was loading.
+
+func playHeldTrack(track *AudioTrack, isHeld func() bool) {
+	tryPlaySound(track, g_selectedDevice)
+
+	if !isHeld() {
+		stopTrack(track)
+	}
+}
+
+func triggerTrackDown(track *AudioTrack, isHeld func() bool) {
+	switch track.TriggerMode {
+	case triggerHold:
+		go playHeldTrack(track, isHeld)
+	case triggerToggle:
+		if isCurrentTrack(track) {
+			go stopTrack(track)
+
+			return
+		}
+
+		go tryPlaySound(track, g_selectedDevice)
+	case triggerQueue:
+		go track.Queue("")
+	default:
+		go tryPlaySound(track, g_selectedDevice)
+	}
+}
+
+func triggerTrackUp(track *AudioTrack) {
+	if track.TriggerMode != triggerHold {
+		return
+	}
+
+	go stopTrack(track)
+}
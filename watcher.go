@@ -0,0 +1,499 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"waveboard/fixes/ui"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type WatchChange struct {
+	Op       fsnotify.Op
+	LastSeen time.Time
+}
+
+type LibraryChange struct {
+	Op      fsnotify.Op
+	Path    string
+	OldPath string
+}
+
+// Files are still being written when they are created, so changes are applied once they settle.
+
+const watchSettleDelay = 750 * time.Millisecond
+const watchRenameWindow = 1500 * time.Millisecond
+const watchTickInterval = 250 * time.Millisecond
+
+var g_libraryWatcher *fsnotify.Watcher
+
+func watchLibrary() error {
+	if g_libraryWatcher != nil {
+		cleanLibraryWatch()
+	}
+
+	if getDownloadRoot() == nil {
+		return nil
+	}
+
+	watcher, watcherError := fsnotify.NewWatcher()
+
+	if watcherError != nil {
+		return watcherError
+	}
+
+	for _, item := range g_appSettings.Libraries {
+		if !item.Enabled {
+			continue
+		}
+
+		if addError := addWatchDirectories(watcher, item.FullPath()); addError != nil {
+			watcher.Close()
+
+			return addError
+		}
+	}
+
+	g_libraryWatcher = watcher
+
+	go watchLibraryCallback(watcher)
+
+	return nil
+}
+
+func cleanLibraryWatch() {
+	g_libraryWatcher.Close()
+	g_libraryWatcher = nil
+}
+
+func addWatchDirectories(watcher *fsnotify.Watcher, rootPath string) error {
+	return filepath.WalkDir(rootPath, func(fullPath string, dirEntry fs.DirEntry, walkError error) error {
+		if walkError != nil {
+			return walkError
+		}
+
+		if !dirEntry.IsDir() {
+			return nil
+		}
+
+		return watcher.Add(fullPath)
+	})
+}
+
+func watchLibraryCallback(watcher *fsnotify.Watcher) {
+	pendingChanges := make(map[string]*WatchChange)
+	renamedPaths := make(map[string]time.Time)
+
+	ticker := time.NewTicker(watchTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			currentTime := time.Now()
+
+			switch {
+			case event.Has(fsnotify.Create):
+				if fileInfo, statError := os.Stat(event.Name); statError == nil && fileInfo.IsDir() {
+					if addError := addWatchDirectories(watcher, event.Name); addError != nil {
+						ui.QueueMain(func() { logToEntry(addError.Error()) })
+					}
+				}
+
+				pendingChanges[event.Name] = &WatchChange{fsnotify.Create, currentTime}
+			case event.Has(fsnotify.Write):
+				if change, exists := pendingChanges[event.Name]; exists {
+					change.LastSeen = currentTime
+
+					continue
+				}
+
+				pendingChanges[event.Name] = &WatchChange{fsnotify.Write, currentTime}
+			case event.Has(fsnotify.Remove):
+				pendingChanges[event.Name] = &WatchChange{fsnotify.Remove, currentTime}
+			case event.Has(fsnotify.Rename):
+				delete(pendingChanges, event.Name)
+				renamedPaths[event.Name] = currentTime
+			}
+		case watchError, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			ui.QueueMain(func() { logToEntry(watchError.Error()) })
+		case <-ticker.C:
+			var libraryChanges []LibraryChange
+
+			currentTime := time.Now()
+
+			for changePath, change := range pendingChanges {
+				if currentTime.Sub(change.LastSeen) < watchSettleDelay {
+					continue
+				}
+
+				delete(pendingChanges, changePath)
+
+				if change.Op == fsnotify.Create {
+					if oldPath := matchRenamedPath(changePath, renamedPaths); oldPath != "" {
+						delete(renamedPaths, oldPath)
+
+						libraryChanges = append(libraryChanges, LibraryChange{fsnotify.Rename, changePath, oldPath})
+
+						continue
+					}
+				}
+
+				libraryChanges = append(libraryChanges, LibraryChange{change.Op, changePath, ""})
+			}
+
+			// Renamed paths without a matching creation were moved outside of the library
+
+			for oldPath, renameTime := range renamedPaths {
+				if currentTime.Sub(renameTime) < watchRenameWindow {
+					continue
+				}
+
+				delete(renamedPaths, oldPath)
+
+				libraryChanges = append(libraryChanges, LibraryChange{fsnotify.Remove, oldPath, ""})
+			}
+
+			if len(libraryChanges) == 0 {
+				continue
+			}
+
+			ui.QueueMain(func() { applyLibraryChanges(libraryChanges) })
+		}
+	}
+}
+
+// matchRenamedPath finds the old path of a created file or directory.
+// Files are matched by their cached size when several were renamed at once.
+
+func matchRenamedPath(createdPath string, renamedPaths map[string]time.Time) string {
+	if len(renamedPaths) == 0 {
+		return ""
+	}
+
+	fileInfo, statError := os.Stat(createdPath)
+
+	if statError != nil {
+		return ""
+	}
+
+	var candidates []string
+
+	for oldPath := range renamedPaths {
+		if fileInfo.IsDir() == extensionsMap[filepath.Ext(oldPath)] {
+			continue
+		}
+
+		candidates = append(candidates, oldPath)
+	}
+
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	if fileInfo.IsDir() {
+		return ""
+	}
+
+	var matchedPath string
+
+	g_metadataMutex.Lock()
+	defer g_metadataMutex.Unlock()
+
+	for _, oldPath := range candidates {
+		metadata, exists := g_metadataCache[filepath.ToSlash(oldPath)]
+
+		if !exists || metadata.Size != fileInfo.Size() {
+			continue
+		}
+
+		if matchedPath != "" {
+			return ""
+		}
+
+		matchedPath = oldPath
+	}
+
+	return matchedPath
+}
+
+func isWithinPath(fullPath string, parentPath string) bool {
+	return fullPath == parentPath || strings.HasPrefix(fullPath, parentPath+string(filepath.Separator))
+}
+
+func applyLibraryChanges(libraryChanges []LibraryChange) {
+	var addedTracks []*AudioTrack
+	var modifiedTracks []*AudioTrack
+
+	listChanged := false
+
+	for _, change := range libraryChanges {
+		switch change.Op {
+		case fsnotify.Create:
+			newTracks := addLibraryPath(change.Path)
+			addedTracks = append(addedTracks, newTracks...)
+			listChanged = listChanged || len(newTracks) != 0
+		case fsnotify.Write:
+			if track := modifyLibraryPath(change.Path); track != nil {
+				modifiedTracks = append(modifiedTracks, track)
+			}
+		case fsnotify.Remove:
+			listChanged = removeLibraryPath(change.Path) || listChanged
+		case fsnotify.Rename:
+			renamedTracks := renameLibraryPath(change.OldPath, change.Path)
+			modifiedTracks = append(modifiedTracks, renamedTracks...)
+			listChanged = listChanged || len(renamedTracks) != 0
+		}
+	}
+
+	if len(addedTracks) != 0 || len(modifiedTracks) != 0 {
+		go indexMetadata(append(addedTracks, modifiedTracks...), g_metadataGeneration.Load(), false)
+	}
+
+	if !listChanged {
+		return
+	}
+
+	reindexTracks()
+	rebuildTrackIndex()
+	refreshFilteredList()
+
+	// Rows have moved, so the rows waiting for a key or a pad may now be other tracks
+
+	g_bindingRow = -1
+	g_midiLearnRow = -1
+
+	g_filesTableModel.RowInserted(0)
+
+	if len(addedTracks) != 0 {
+		logToEntry("Added %d tracks from the library directories", len(addedTracks))
+	}
+}
+
+func findTrackByPath(trackPath string) *AudioTrack {
+	for _, item := range g_tracksList {
+		if item.Path == trackPath {
+			return item
+		}
+	}
+
+	return nil
+}
+
+func addLibraryPath(addedPath string) []*AudioTrack {
+	var addedTracks []*AudioTrack
+
+	filepath.WalkDir(addedPath, func(fullFilePath string, dirEntry fs.DirEntry, walkError error) error {
+		if walkError != nil {
+			return nil
+		}
+
+		if dirEntry.IsDir() || !(extensionsMap[filepath.Ext(fullFilePath)]) || findTrackByPath(fullFilePath) != nil {
+			return nil
+		}
+
+		trackRoot := findTrackRoot(fullFilePath)
+
+		if trackRoot == nil {
+			return nil
+		}
+
+		track := newAudioTrack(fullFilePath, trackRoot, assignTrackID(fullFilePath, trackRoot, nil), len(g_tracksList))
+
+		g_tracksList = append(g_tracksList, track)
+		addedTracks = append(addedTracks, track)
+
+		return nil
+	})
+
+	if len(addedTracks) != 0 {
+		go trySaveSettings()
+	}
+
+	return addedTracks
+}
+
+// modifyLibraryPath drops the cached audio of a rewritten file, unless it is playing.
+
+func modifyLibraryPath(modifiedPath string) *AudioTrack {
+	track := findTrackByPath(modifiedPath)
+
+	if track == nil {
+		return nil
+	}
+
+	if track == g_currentTrack {
+		return track
+	}
+
+	track.Data = nil
+	track.SampleRatio = -1
+
+	if track.Virtual != nil {
+		track.ClearVirtual()
+	}
+
+	if track.Resampler != nil {
+		track.ClearResampler()
+	}
+
+	return track
+}
+
+func removeLibraryPath(removedPath string) bool {
+	keptTracks := g_tracksList[:0]
+	removed := false
+
+	for _, item := range g_tracksList {
+		if !isWithinPath(item.Path, removedPath) {
+			keptTracks = append(keptTracks, item)
+
+			continue
+		}
+
+		removed = true
+
+		item.DetachSettings()
+
+		if g_keysMap[item.Binding] == item {
+			delete(g_keysMap, item.Binding)
+		}
+
+		item.Binding = 0
+
+		item.UnmapMIDI()
+		item.MIDIBinding = ""
+
+		if item == g_currentTrack || g_audioQueue.Contains(item) {
+			item.ID = -1
+
+			continue
+		}
+
+		item.ClearTrackSafe()
+	}
+
+	for index := len(keptTracks); index < len(g_tracksList); index++ {
+		g_tracksList[index] = nil
+	}
+
+	g_tracksList = keptTracks
+
+	return removed
+}
+
+func renameLibraryPath(oldPath string, newPath string) []*AudioTrack {
+	var renamedTracks []*AudioTrack
+
+	for _, item := range g_tracksList {
+		if !isWithinPath(item.Path, oldPath) {
+			continue
+		}
+
+		renamedTracks = append(renamedTracks, item)
+	}
+
+	if len(renamedTracks) == 0 {
+		addLibraryPath(newPath)
+
+		return nil
+	}
+
+	for _, item := range renamedTracks {
+		trackPath := newPath + strings.TrimPrefix(item.Path, oldPath)
+
+		if !extensionsMap[filepath.Ext(trackPath)] {
+			removeLibraryPath(item.Path)
+
+			continue
+		}
+
+		// Renaming over an existing file replaces it
+
+		if existingTrack := findTrackByPath(trackPath); existingTrack != nil && existingTrack != item {
+			removeLibraryPath(trackPath)
+		}
+
+		oldKey, newKey := filepath.ToSlash(item.Path), filepath.ToSlash(trackPath)
+
+		trackRoot := findTrackRoot(trackPath)
+
+		if trackRoot == nil {
+			trackRoot = item.Root
+		}
+
+		oldTracks, oldTrackIDs, oldSettingsKey := settingsLocation(item.Root, item.Path)
+		newTracks, newTrackIDs, newSettingsKey := settingsLocation(trackRoot, trackPath)
+
+		if savedTrack, exists := oldTracks[oldSettingsKey]; exists {
+			delete(oldTracks, oldSettingsKey)
+			newTracks[newSettingsKey] = savedTrack
+		}
+
+		// Moving between roots with different prefixes changes the ID sequence
+
+		if trackID, exists := oldTrackIDs[oldSettingsKey]; exists {
+			delete(oldTrackIDs, oldSettingsKey)
+
+			if rootPrefixKey(trackRoot) != rootPrefixKey(item.Root) {
+				trackID = nextTrackID(trackRoot)
+				item.ID = trackID
+			}
+
+			newTrackIDs[newSettingsKey] = trackID
+		}
+
+		g_metadataMutex.Lock()
+
+		if metadata, exists := g_metadataCache[oldKey]; exists {
+			delete(g_metadataCache, oldKey)
+			g_metadataCache[newKey] = metadata
+		}
+
+		g_metadataMutex.Unlock()
+
+		item.Path = trackPath
+		item.Extension = filepath.Ext(trackPath)
+		item.Name = strings.TrimSuffix(filepath.Base(trackPath), item.Extension)
+		item.Root = trackRoot
+	}
+
+	go trySaveSettings()
+
+	return renamedTracks
+}
+
+// DetachSettings keeps the saved settings of a track which is going away, as clearing it resets its binding.
+
+func (track *AudioTrack) DetachSettings() {
+	savedTracks, _, settingsKey := settingsLocation(track.Root, track.Path)
+
+	if savedTrack, exists := savedTracks[settingsKey]; !exists || savedTrack != track {
+		return
+	}
+
+	savedTracks[settingsKey] = &AudioTrack{
+		Root:        track.Root,
+		Volume:      track.Volume,
+		Binding:     track.Binding,
+		TriggerMode: track.TriggerMode,
+		Bank:        track.Bank,
+		MIDIBinding: track.MIDIBinding,
+		Plays:       track.Plays,
+		Hash:        track.Hash,
+		Categories:  track.Categories,
+		Weight:      track.Weight,
+	}
+}